@@ -0,0 +1,80 @@
+package build
+
+import (
+	"buildServer/upload"
+	"buildServer/utils"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CompileFunctions compiles every Go file in the project's api folder to the WASI module next to it,
+// `api/hello.go` becomes `api/hello.wasm`. Every file is a program of its own, code the functions share
+// lives in a package outside the api folder. Modules built some other way, with TinyGo from the build
+// command for example, are uploaded as they are.
+func CompileFunctions(buildId int, projectDir string) error {
+	functionsDir := filepath.Join(projectDir, upload.FunctionsFolder)
+
+	var sources []string
+
+	walkErr := filepath.WalkDir(functionsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() && filepath.Ext(path) == ".go" && !strings.HasSuffix(path, "_test.go") {
+			sources = append(sources, path)
+		}
+
+		return nil
+	})
+	if os.IsNotExist(walkErr) {
+		return nil
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+
+	if len(sources) == 0 {
+		return nil
+	}
+
+	// the count is checked again once the modules exist, checking it here saves compiling what would be refused
+	if len(sources) > upload.MaxFunctions {
+		return fmt.Errorf("[FUNCTIONS] %d functions found, at most %d are allowed", len(sources), upload.MaxFunctions)
+	}
+
+	if err := utils.UpdateBuildLog(buildId, fmt.Sprintf("[FUNCTIONS] Compiling %d functions", len(sources))); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for _, source := range sources {
+		module := strings.TrimSuffix(filepath.Base(source), ".go") + ".wasm"
+
+		cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-o", module, filepath.Base(source))
+		cmd.Dir = filepath.Dir(source)
+		// the project's environment variables are not handed to the compiler, functions read them when they run.
+		// GOTOOLCHAIN=local keeps a go.mod from making the build server download another toolchain
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "CGO_ENABLED=0", "GOTOOLCHAIN=local")
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("[FUNCTIONS] compiling took too long")
+			}
+
+			relPath, _ := filepath.Rel(projectDir, source)
+			return fmt.Errorf("[FUNCTIONS] %s failed to compile: %s", filepath.ToSlash(relPath), string(output))
+		}
+	}
+
+	return nil
+}
@@ -3,11 +3,11 @@ package build
 import (
 	"buildServer/auth"
 	"buildServer/config"
+	"buildServer/gitprovider"
 	"buildServer/utils"
 	"context"
-	"encoding/json"
+	"database/sql"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -64,40 +64,6 @@ func InstallDependencies(buildId int, nodeVersion, installCommand, dir string) e
 	return nil
 }
 
-func GetArchiveURL(githubId int, userId int) (string, error) {
-	accessToken, accessTokenErr := auth.GetAccessToken(userId)
-	if accessTokenErr != nil {
-		return "", accessTokenErr
-	}
-
-	headers := map[string]string{
-		"Authorization": "Bearer " + accessToken,
-	}
-
-	repoResp, repoErr := utils.Request("GET", fmt.Sprintf("https://api.github.com/repositories/%d", githubId), &headers, nil, nil)
-	if repoErr != nil {
-		return "", repoErr
-	}
-
-	defer repoResp.Body.Close()
-
-	repoBody, repoReadErr := io.ReadAll(repoResp.Body)
-	if repoReadErr != nil {
-		return "", repoErr
-	}
-
-	var repoResponse struct {
-		ArchiveURL string `json:"archive_url"`
-	}
-
-	deconstructorErr := json.Unmarshal(repoBody, &repoResponse)
-	if deconstructorErr != nil {
-		return "", deconstructorErr
-	}
-
-	return repoResponse.ArchiveURL, nil
-}
-
 func GetDefaults(buildId int) (string, string, string, string, string, error) {
 	var installCommand, buildCommand, outputFolder, directory, nodeVersion string
 
@@ -115,61 +81,47 @@ func GetDefaults(buildId int) (string, string, string, string, string, error) {
 	return directory, installCommand, buildCommand, outputFolder, nodeVersion, nil
 }
 
-func GetUserIdAndProjectId(buildId int) (*int, *int, *int, error) {
-	var userId, projectId, githubId int
+// GetUserIdAndProjectId also returns the project's repository and the commit the build was queued for
+func GetUserIdAndProjectId(buildId int) (*int, *int, *gitprovider.Repo, *string, error) {
+	var userId, projectId int
+	var commitSha string
+	var repoHost, repoToken sql.NullString
 
-	retQuery := `SELECT p.user_id, p.id, p.github_id FROM "deploy-io".projects p JOIN "deploy-io".builds b ON p.id = b.project_id WHERE b.id = $1`
-	queryErr := config.DataBase.QueryRow(retQuery, buildId).Scan(&userId, &projectId, &githubId)
-	if queryErr != nil {
-		return nil, nil, nil, queryErr
-	}
+	repo := gitprovider.Repo{}
 
-	return &userId, &projectId, &githubId, nil
-}
-
-func CloneAndExtractRepository(archiveURL string, userId int, buildId int) (string, error) {
-	accessToken, accessTokenErr := auth.GetAccessToken(userId)
-	if accessTokenErr != nil {
-		return "", accessTokenErr
-	}
-
-	headers := map[string]string{
-		"Authorization": "Bearer " + accessToken,
-	}
-
-	resp, err := utils.Request("GET", archiveURL, &headers, nil, nil)
-	if err != nil {
-		return "", err
-	}
-
-	defer resp.Body.Close()
-
-	file, err := os.Create(fmt.Sprintf("%s%d.tar", utils.GetCurDir()+"/tmp/", buildId))
-	if err != nil {
-		return "", err
+	retQuery := `
+		SELECT p.user_id, p.id, p.provider, COALESCE(p.repo, p.github_id::text), p.repo_host, p.repo_token, b.commit_hash
+		FROM "deploy-io".projects p JOIN "deploy-io".builds b ON p.id = b.project_id WHERE b.id = $1
+	`
+	queryErr := config.DataBase.QueryRow(retQuery, buildId).Scan(&userId, &projectId, &repo.Provider, &repo.Id, &repoHost, &repoToken, &commitSha)
+	if queryErr != nil {
+		return nil, nil, nil, nil, queryErr
 	}
 
-	defer file.Close()
+	repo.UserId = userId
+	repo.Host = repoHost.String
 
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return "", err
+	if repoToken.Valid && len(repoToken.String) > 0 {
+		token, decryptErr := auth.Decrypt(repoToken.String)
+		if decryptErr != nil {
+			return nil, nil, nil, nil, decryptErr
+		}
+		repo.Token = token
 	}
 
-	cmd := exec.Command("tar", "-xvzf", file.Name(), "-C", utils.GetCurDir()+"/tmp")
+	return &userId, &projectId, &repo, &commitSha, nil
+}
 
-	extractionOutput, err := cmd.Output()
-	if err != nil {
-		return "", err
+// CloneAndExtractRepository places the files of the build's commit under tmp and returns the directory they are in
+func CloneAndExtractRepository(repo gitprovider.Repo, commitSha string, buildId int) (string, error) {
+	provider, providerErr := gitprovider.For(repo.Provider)
+	if providerErr != nil {
+		return "", providerErr
 	}
 
-	files := strings.Split(string(extractionOutput), "\n")
-
-	directoryName := files[0]
-
-	removeErr := os.Remove(file.Name())
-	if removeErr != nil {
-		return "", removeErr
+	directoryName, fetchErr := provider.FetchSource(repo, commitSha, utils.GetCurDir()+"/tmp", buildId)
+	if fetchErr != nil {
+		return "", fetchErr
 	}
 
 	updateErr := utils.UpdateBuildLog(buildId, "[CLONE] Extracted repository and placed at "+directoryName)
@@ -0,0 +1,84 @@
+package gitprovider
+
+import (
+	"buildServer/outbound"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Git reads public repositories over https with the git client, for hosts that have no archive api
+type Git struct{}
+
+func (Git) FetchSource(repo Repo, sha string, dir string, buildId int) (string, error) {
+	if !strings.HasPrefix(repo.Id, "https://") {
+		return "", fmt.Errorf("[GIT] repository has to be an https url")
+	}
+
+	// git resolves the host itself, pinning it to the address that was checked keeps a name that
+	// resolves differently by the time git connects from reaching the internal network
+	resolve, resolveErr := outbound.CurlResolve(repo.Id)
+	if resolveErr != nil {
+		return "", fmt.Errorf("[GIT] repository has to be on a public address")
+	}
+
+	remote := []string{
+		"-c", "http.curloptResolve=" + resolve,
+		"-c", "http.followRedirects=false",
+		"-c", "protocol.allow=never",
+		"-c", "protocol.https.allow=always",
+	}
+
+	directoryName := fmt.Sprintf("%d-source", buildId)
+	sourceDir := dir + "/" + directoryName
+
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		return "", err
+	}
+
+	// only the commit is fetched, servers that refuse to hand out a commit by its sha get a full clone instead
+	shallowErr := runGit(sourceDir, nil, "init", "--quiet")
+	if shallowErr == nil {
+		shallowErr = runGit(sourceDir, remote, "fetch", "--quiet", "--depth", "1", repo.Id, sha)
+	}
+	if shallowErr == nil {
+		shallowErr = runGit(sourceDir, nil, "checkout", "--quiet", "FETCH_HEAD")
+	}
+
+	if shallowErr != nil {
+		if err := os.RemoveAll(sourceDir); err != nil {
+			return "", err
+		}
+
+		if err := runGit(dir, remote, "clone", "--quiet", repo.Id, directoryName); err != nil {
+			return "", err
+		}
+
+		if err := runGit(sourceDir, nil, "checkout", "--quiet", sha); err != nil {
+			return "", err
+		}
+	}
+
+	return directoryName + "/", nil
+}
+
+// runGit runs the git command in dir, options are git's own and go before the command
+func runGit(dir string, options []string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append(options, args...)...)
+	cmd.Dir = dir
+	// a repository that asks for credentials is not public, fail instead of waiting for a prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("[GIT] git %s failed: %s", args[0], strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package gitprovider
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type Gitea struct{}
+
+func (Gitea) FetchSource(repo Repo, sha string, dir string, buildId int) (string, error) {
+	host, hostErr := hostURL(repo.Host, "")
+	if hostErr != nil {
+		return "", hostErr
+	}
+
+	headers := map[string]string{}
+	if len(repo.Token) > 0 {
+		headers["Authorization"] = "token " + repo.Token
+	}
+
+	archiveURL := fmt.Sprintf("%s/api/v1/repos/%s/archive/%s.tar.gz", host, repo.Id, url.PathEscape(sha))
+
+	return fetchTarball(archiveURL, headers, dir, buildId)
+}
@@ -0,0 +1,26 @@
+package gitprovider
+
+import (
+	"buildServer/auth"
+	"fmt"
+)
+
+type GitHub struct{}
+
+func (GitHub) FetchSource(repo Repo, sha string, dir string, buildId int) (string, error) {
+	var githubId int
+	if _, err := fmt.Sscanf(repo.Id, "%d", &githubId); err != nil {
+		return "", fmt.Errorf("[GIT] GitHub repository id %q is not a number", repo.Id)
+	}
+
+	accessToken, accessTokenErr := auth.RepositoryToken(githubId, repo.UserId)
+	if accessTokenErr != nil {
+		return "", accessTokenErr
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}
+
+	return fetchTarball(fmt.Sprintf("https://api.github.com/repositories/%d/tarball/%s", githubId, sha), headers, dir, buildId)
+}
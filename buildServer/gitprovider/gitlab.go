@@ -0,0 +1,24 @@
+package gitprovider
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type GitLab struct{}
+
+func (GitLab) FetchSource(repo Repo, sha string, dir string, buildId int) (string, error) {
+	host, hostErr := hostURL(repo.Host, "https://gitlab.com")
+	if hostErr != nil {
+		return "", hostErr
+	}
+
+	headers := map[string]string{}
+	if len(repo.Token) > 0 {
+		headers["PRIVATE-TOKEN"] = repo.Token
+	}
+
+	archiveURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/archive.tar.gz?sha=%s", host, url.PathEscape(repo.Id), url.QueryEscape(sha))
+
+	return fetchTarball(archiveURL, headers, dir, buildId)
+}
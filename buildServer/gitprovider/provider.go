@@ -0,0 +1,116 @@
+package gitprovider
+
+import (
+	"buildServer/outbound"
+	"buildServer/utils"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hosts come from users, archives are downloaded through a client that only reaches public addresses
+var client = outbound.HTTPClient(10 * time.Minute)
+
+// Repo is a project's repository and what is needed to read it
+type Repo struct {
+	Provider string
+	// the numeric id on GitHub, the numeric id or `group/project` path on GitLab,
+	// `owner/name` on Gitea and the https url for plain git
+	Id string
+	// base url of a self hosted GitLab or Gitea, empty for gitlab.com
+	Host string
+	// access token of a private GitLab or Gitea repository
+	Token string
+	// GitHub repositories fall back to the OAuth token of the user who connected them
+	UserId int
+}
+
+type Provider interface {
+	// FetchSource places the files of the commit in a new directory under dir and returns the directory's name
+	FetchSource(repo Repo, sha string, dir string, buildId int) (string, error)
+}
+
+var providers = map[string]Provider{
+	"github": GitHub{},
+	"gitlab": GitLab{},
+	"gitea":  Gitea{},
+	"git":    Git{},
+}
+
+func For(name string) (Provider, error) {
+	provider, found := providers[name]
+	if !found {
+		return nil, fmt.Errorf("[GIT] unknown provider %q", name)
+	}
+
+	return provider, nil
+}
+
+// fetchTarball downloads a gzipped tarball and extracts it under dir, archives hold a single top level directory
+func fetchTarball(archiveURL string, headers map[string]string, dir string, buildId int) (string, error) {
+	resp, err := utils.RequestWith(client, "GET", archiveURL, &headers, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("[GIT] archive download answered %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(fmt.Sprintf("%s/%d.tar", dir, buildId))
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(file, resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("tar", "-xvzf", file.Name(), "-C", dir)
+
+	extractionOutput, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	files := strings.Split(string(extractionOutput), "\n")
+
+	directoryName := files[0]
+
+	removeErr := os.Remove(file.Name())
+	if removeErr != nil {
+		return "", removeErr
+	}
+
+	return directoryName, nil
+}
+
+// hostURL is the base url of a self hosted provider, only https urls of public addresses are accepted
+func hostURL(host string, fallback string) (string, error) {
+	if len(strings.TrimSpace(host)) == 0 {
+		if len(fallback) == 0 {
+			return "", fmt.Errorf("[GIT] the provider needs a host")
+		}
+		return fallback, nil
+	}
+
+	parsed, err := url.Parse(host)
+	if err != nil || parsed.Scheme != "https" || len(parsed.Host) == 0 {
+		return "", fmt.Errorf("[GIT] host has to be an https url")
+	}
+
+	if err := outbound.CheckURL(host); err != nil {
+		return "", fmt.Errorf("[GIT] host has to be a public address")
+	}
+
+	return strings.TrimSuffix(host, "/"), nil
+}
@@ -0,0 +1,169 @@
+package mimetypes
+
+import (
+	"mime"
+	"strings"
+)
+
+// extension database that takes precedence over the host's mime.types, which differs between machines and images
+var types = map[string]string{
+	// documents
+	".html":        "text/html",
+	".htm":         "text/html",
+	".xhtml":       "application/xhtml+xml",
+	".css":         "text/css",
+	".txt":         "text/plain",
+	".text":        "text/plain",
+	".md":          "text/markdown",
+	".csv":         "text/csv",
+	".tsv":         "text/tab-separated-values",
+	".ics":         "text/calendar",
+	".vtt":         "text/vtt",
+	".xml":         "application/xml",
+	".xsl":         "application/xml",
+	".xsd":         "application/xml",
+	".rss":         "application/rss+xml",
+	".atom":        "application/atom+xml",
+	".pdf":         "application/pdf",
+	".rtf":         "application/rtf",
+	".doc":         "application/msword",
+	".docx":        "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":         "application/vnd.ms-excel",
+	".xlsx":        "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":         "application/vnd.ms-powerpoint",
+	".pptx":        "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":         "application/vnd.oasis.opendocument.text",
+	".epub":        "application/epub+zip",
+	".yaml":        "application/yaml",
+	".yml":         "application/yaml",
+	".toml":        "application/toml",
+	".webmanifest": "application/manifest+json",
+	".appcache":    "text/cache-manifest",
+
+	// scripts and data
+	".js":     "text/javascript",
+	".mjs":    "text/javascript",
+	".cjs":    "text/javascript",
+	".json":   "application/json",
+	".jsonld": "application/ld+json",
+	".map":    "application/json",
+	".wasm":   "application/wasm",
+	".bin":    "application/octet-stream",
+
+	// images
+	".png":   "image/png",
+	".apng":  "image/apng",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".jfif":  "image/jpeg",
+	".pjpeg": "image/jpeg",
+	".gif":   "image/gif",
+	".svg":   "image/svg+xml",
+	".svgz":  "image/svg+xml",
+	".webp":  "image/webp",
+	".avif":  "image/avif",
+	".ico":   "image/x-icon",
+	".cur":   "image/x-icon",
+	".bmp":   "image/bmp",
+	".tif":   "image/tiff",
+	".tiff":  "image/tiff",
+	".heic":  "image/heic",
+	".heif":  "image/heif",
+	".jxl":   "image/jxl",
+
+	// fonts
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".eot":   "application/vnd.ms-fontobject",
+
+	// audio
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/opus",
+	".flac": "audio/flac",
+	".aac":  "audio/aac",
+	".m4a":  "audio/mp4",
+	".weba": "audio/webm",
+	".mid":  "audio/midi",
+	".midi": "audio/midi",
+
+	// video
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+	".mov":  "video/quicktime",
+	".avi":  "video/x-msvideo",
+	".mpeg": "video/mpeg",
+	".mpg":  "video/mpeg",
+	".mkv":  "video/x-matroska",
+	".ts":   "video/mp2t",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".mpd":  "application/dash+xml",
+
+	// archives
+	".zip": "application/zip",
+	".gz":  "application/gzip",
+	".tgz": "application/gzip",
+	".tar": "application/x-tar",
+	".bz2": "application/x-bzip2",
+	".7z":  "application/x-7z-compressed",
+	".rar": "application/vnd.rar",
+	".br":  "application/x-brotli",
+
+	// misc
+	".glb":  "model/gltf-binary",
+	".gltf": "model/gltf+json",
+	".usdz": "model/vnd.usdz+zip",
+	".apk":  "application/vnd.android.package-archive",
+	".dmg":  "application/x-apple-diskimage",
+	".exe":  "application/vnd.microsoft.portable-executable",
+	".swf":  "application/x-shockwave-flash",
+	".pem":  "application/x-pem-file",
+	".crt":  "application/x-x509-ca-cert",
+}
+
+// TypeByExtension returns the content type for a file extension such as ".woff2",
+// with `charset=utf-8` added for textual types. Unknown extensions return an empty string.
+func TypeByExtension(ext string) string {
+	ext = strings.ToLower(ext)
+
+	contentType, found := types[ext]
+	if !found {
+		contentType = mime.TypeByExtension(ext)
+		if len(contentType) == 0 {
+			return ""
+		}
+
+		contentType, _, _ = strings.Cut(contentType, ";")
+	}
+
+	if IsText(contentType) {
+		return contentType + "; charset=utf-8"
+	}
+
+	return contentType
+}
+
+// IsText reports whether a content type carries text, which is what a charset applies to
+func IsText(contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.TrimSpace(contentType)
+
+	if strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+
+	switch contentType {
+	case "application/json", "application/ld+json", "application/manifest+json",
+		"application/xml", "application/xhtml+xml", "application/rss+xml", "application/atom+xml",
+		"application/yaml", "application/toml", "image/svg+xml":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Dialer only connects to public addresses. The check runs on the address that is about to be dialed,
+// after DNS has been resolved, so a name that resolves to a public address once and an internal one later
+// can not slip through
+var Dialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		if !IsPublic(net.ParseIP(host)) {
+			return fmt.Errorf("[OUTBOUND] %s is not a public address", host)
+		}
+
+		return nil
+	},
+}
+
+// IsPublic reports whether the address is reachable on the internet, loopback, private, link local
+// (cloud metadata lives there), multicast and unspecified addresses are not
+func IsPublic(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// HTTPClient is an http.Client that only reaches public addresses, redirects are dialed through the same check
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         Dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// CheckURL resolves the url's host and refuses it when any of its addresses is not public, the
+// connection is checked again when it is made since the name may resolve differently by then
+func CheckURL(rawURL string) error {
+	_, err := publicAddress(rawURL)
+	return err
+}
+
+// CurlResolve checks the url like CheckURL and returns a `host:port:address` entry for curl's resolve option.
+// Handing it to git as http.curloptResolve makes git connect to the address that was checked,
+// git resolves names itself and can not be given Dialer
+func CurlResolve(rawURL string) (string, error) {
+	address, err := publicAddress(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, _ := url.Parse(rawURL)
+
+	port := parsed.Port()
+	if len(port) == 0 {
+		port = "443"
+		if parsed.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	host, ip := parsed.Hostname(), address.String()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if address.To4() == nil {
+		ip = "[" + ip + "]"
+	}
+
+	return host + ":" + port + ":" + ip, nil
+}
+
+// publicAddress resolves the url's host and returns its first address when all of them are public
+func publicAddress(rawURL string) (net.IP, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || len(parsed.Hostname()) == 0 {
+		return nil, fmt.Errorf("[OUTBOUND] %q is not a valid url", rawURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addresses, lookupErr := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if lookupErr != nil || len(addresses) == 0 {
+		return nil, fmt.Errorf("[OUTBOUND] %s could not be resolved", parsed.Hostname())
+	}
+
+	for _, address := range addresses {
+		if !IsPublic(address.IP) {
+			return nil, fmt.Errorf("[OUTBOUND] %s points to an address that is not public", parsed.Hostname())
+		}
+	}
+
+	return addresses[0].IP, nil
+}
@@ -3,11 +3,11 @@ package rabbit
 import (
 	"buildServer/build"
 	"buildServer/config"
+	"buildServer/siteconfig"
 	"buildServer/upload"
 	"buildServer/utils"
 	"encoding/json"
 	"log"
-	"strings"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -31,7 +31,7 @@ func ConsumeRabbitQueue(msgs <-chan amqp.Delivery) {
 		d.Ack(true)
 
 		log.Printf("[BUILD] Received job with build id %d", request.BuildId)
-		userId, projectId, githubId, err := build.GetUserIdAndProjectId(request.BuildId)
+		userId, projectId, repo, commitSha, err := build.GetUserIdAndProjectId(request.BuildId)
 		if err != nil {
 			utils.UpdateBuildLog(request.BuildId, err.Error())
 			utils.SetBuildStatus(request.BuildId, "failure")
@@ -45,21 +45,7 @@ func ConsumeRabbitQueue(msgs <-chan amqp.Delivery) {
 			log.Fatalln("[DATABASE] " + qErr.Error())
 		}
 
-		archiveURL, archiveErr := build.GetArchiveURL(*githubId, *userId)
-		if archiveErr != nil {
-			utils.UpdateBuildLog(request.BuildId, archiveErr.Error())
-			utils.SetBuildStatus(request.BuildId, "failure")
-			log.Println("[GETarcURL] erred while getting archieve url " + archiveErr.Error())
-			continue
-		}
-
-		// replace `{archive_format}` with `tarball`
-		archiveURL = strings.ReplaceAll(archiveURL, "{archive_format}", "tarball")
-
-		// remove "{/ref}"
-		archiveURL = strings.ReplaceAll(archiveURL, "{/ref}", "")
-
-		workingDir, cloneErr := build.CloneAndExtractRepository(archiveURL, *userId, request.BuildId)
+		workingDir, cloneErr := build.CloneAndExtractRepository(*repo, *commitSha, request.BuildId)
 		if cloneErr != nil {
 			utils.UpdateBuildLog(request.BuildId, cloneErr.Error())
 			utils.SetBuildStatus(request.BuildId, "failure")
@@ -104,6 +90,44 @@ func ConsumeRabbitQueue(msgs <-chan amqp.Delivery) {
 			continue
 		}
 
+		siteConfig, siteConfigErr := siteconfig.Load(projectDir, projectDir+outputFolder)
+		if siteConfigErr != nil {
+			utils.UpdateBuildLog(request.BuildId, siteConfigErr.Error())
+			utils.SetBuildStatus(request.BuildId, "failure")
+			utils.DeleteDirectory(projectDir)
+			log.Println("[CONFIG] invalid site configuration " + siteConfigErr.Error())
+			continue
+		}
+
+		compileErr := build.CompileFunctions(request.BuildId, projectDir)
+		if compileErr != nil {
+			utils.UpdateBuildLog(request.BuildId, compileErr.Error())
+			utils.SetBuildStatus(request.BuildId, "failure")
+			utils.DeleteDirectory(projectDir)
+			log.Println("[FUNCTIONS] failed to compile functions " + compileErr.Error())
+			continue
+		}
+
+		functions, functionsErr := upload.UploadFunctions(request.BuildId, projectDir)
+		if functionsErr != nil {
+			utils.UpdateBuildLog(request.BuildId, functionsErr.Error())
+			utils.SetBuildStatus(request.BuildId, "failure")
+			utils.DeleteDirectory(projectDir)
+			log.Println("[FUNCTIONS] failed to upload functions " + functionsErr.Error())
+			continue
+		}
+
+		siteConfig.Functions = functions
+
+		siteConfigJSON, marshalErr := json.Marshal(siteConfig)
+		if marshalErr != nil {
+			utils.UpdateBuildLog(request.BuildId, marshalErr.Error())
+			utils.SetBuildStatus(request.BuildId, "failure")
+			utils.DeleteDirectory(projectDir)
+			log.Println("[CONFIG] failed to encode site configuration " + marshalErr.Error())
+			continue
+		}
+
 		uploadErr := upload.UploadProjectFiles(request.BuildId, *userId, workingDir)
 		if uploadErr != nil {
 			utils.UpdateBuildLog(request.BuildId, uploadErr.Error())
@@ -113,6 +137,29 @@ func ConsumeRabbitQueue(msgs <-chan amqp.Delivery) {
 			continue
 		}
 
+		var isCanary bool
+		canaryQuery := `SELECT canary FROM "deploy-io".builds WHERE id = $1`
+		canaryErr := config.DataBase.QueryRow(canaryQuery, request.BuildId).Scan(&isCanary)
+		if canaryErr != nil {
+			utils.UpdateBuildLog(request.BuildId, canaryErr.Error())
+			utils.SetBuildStatus(request.BuildId, "failure")
+			utils.DeleteDirectory(projectDir)
+			log.Println("[CANARY] failed to read the build's canary flag " + canaryErr.Error())
+			continue
+		}
+
+		// a canary is deployed next to the active deployment, the project's canary weight decides who gets it
+		if isCanary {
+			candidateErr := deployCanary(*projectId, request.BuildId, string(siteConfigJSON))
+			if candidateErr != nil {
+				utils.UpdateBuildLog(request.BuildId, candidateErr.Error())
+				utils.SetBuildStatus(request.BuildId, "failure")
+				utils.DeleteDirectory(projectDir)
+				log.Println("[CANARY] failed to deploy candidate " + candidateErr.Error())
+			}
+			continue
+		}
+
 		setFalseQuery := `UPDATE "deploy-io".deployments SET status = false WHERE project_id = $1`
 		_, setFalseErr := config.DataBase.Exec(setFalseQuery, projectId)
 		if setFalseErr != nil {
@@ -123,8 +170,8 @@ func ConsumeRabbitQueue(msgs <-chan amqp.Delivery) {
 			continue
 		}
 
-		insQuery := `INSERT INTO "deploy-io".deployments (project_id, build_id) VALUES ($1, $2)`
-		_, insErr := config.DataBase.Exec(insQuery, projectId, request.BuildId)
+		insQuery := `INSERT INTO "deploy-io".deployments (project_id, build_id, site_config) VALUES ($1, $2, $3)`
+		_, insErr := config.DataBase.Exec(insQuery, projectId, request.BuildId, string(siteConfigJSON))
 		if insErr != nil {
 			utils.UpdateBuildLog(request.BuildId, insErr.Error())
 			utils.SetBuildStatus(request.BuildId, "failure")
@@ -132,5 +179,35 @@ func ConsumeRabbitQueue(msgs <-chan amqp.Delivery) {
 			log.Println("[INSERT] failed to insert new build into deployments " + insErr.Error())
 			continue
 		}
+
+		// a candidate built before this deployment would take traffic back to older code, a newer one stays
+		clearCanaryQuery := `UPDATE "deploy-io".projects SET canary_build_id = NULL WHERE id = $1 AND canary_build_id < $2`
+		if _, clearErr := config.DataBase.Exec(clearCanaryQuery, projectId, request.BuildId); clearErr != nil {
+			log.Println("[CANARY] failed to clear the candidate older than the deployment " + clearErr.Error())
+		}
+
+		// the new deployment is live at this point, a failed cleanup only leaves extra files behind
+		pruneErr := upload.PruneArtifacts(*projectId)
+		if pruneErr != nil {
+			log.Println("[PRUNE] failed to remove old build files " + pruneErr.Error())
+		}
 	}
 }
+
+func deployCanary(projectId int, buildId int, siteConfig string) error {
+	insQuery := `INSERT INTO "deploy-io".deployments (project_id, build_id, status, site_config) VALUES ($1, $2, false, $3)`
+	_, insErr := config.DataBase.Exec(insQuery, projectId, buildId, siteConfig)
+	if insErr != nil {
+		return insErr
+	}
+
+	updateQuery := `UPDATE "deploy-io".projects SET canary_build_id = $1 WHERE id = $2`
+	_, updateErr := config.DataBase.Exec(updateQuery, buildId, projectId)
+	if updateErr != nil {
+		return updateErr
+	}
+
+	log.Printf("[CANARY] build %d is the candidate of project %d\n", buildId, projectId)
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package siteconfig
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// attribute that marks a form to be handled by the static server
+	FormAttribute = "data-deployio"
+	// where to send the visitor after a submission, the page of the form when missing
+	FormRedirectAttribute = "data-deployio-redirect"
+	// hidden field left empty by people, a filled in value marks the submission as spam
+	HoneypotField = "deployio-honeypot"
+
+	maxForms = 100
+)
+
+type Form struct {
+	Name     string   `json:"name"`
+	Action   string   `json:"action"`
+	Page     string   `json:"page"`
+	Redirect string   `json:"redirect,omitempty"`
+	Fields   []string `json:"fields"`
+}
+
+// scanForms walks every html file of the output folder looking for `<form data-deployio>`.
+// When several forms post to the same path only the first one found is kept.
+func scanForms(outputDir string) ([]Form, error) {
+	var forms []Form
+	actions := map[string]bool{}
+
+	walkErr := filepath.Walk(outputDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(filePath), ".html") {
+			return nil
+		}
+
+		relPath, relErr := filepath.Rel(outputDir, filePath)
+		if relErr != nil {
+			return relErr
+		}
+
+		pageForms, parseErr := parseForms(filePath, pagePath(relPath))
+		if parseErr != nil {
+			return fmt.Errorf("[CONFIG] %s: %v", relPath, parseErr)
+		}
+
+		for _, form := range pageForms {
+			if actions[form.Action] {
+				continue
+			}
+
+			actions[form.Action] = true
+			forms = append(forms, form)
+		}
+
+		return nil
+	})
+	if os.IsNotExist(walkErr) {
+		return nil, nil
+	}
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	if len(forms) > maxForms {
+		return nil, fmt.Errorf("[CONFIG] %d forms found, at most %d are allowed", len(forms), maxForms)
+	}
+
+	return forms, nil
+}
+
+func parseForms(filePath string, page string) ([]Form, error) {
+	file, openErr := os.Open(filePath)
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	defer file.Close()
+
+	document, parseErr := html.Parse(file)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	var forms []Form
+
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "form" {
+			if marker, found := attribute(node, FormAttribute); found {
+				if form, ok := newForm(node, marker, page); ok {
+					forms = append(forms, form)
+				}
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+
+	visit(document)
+
+	return forms, nil
+}
+
+// newForm returns false for forms posting to another origin, those never reach the static server
+func newForm(node *html.Node, marker string, page string) (Form, bool) {
+	action, _ := attribute(node, "action")
+	action, _, _ = strings.Cut(action, "?")
+	action, _, _ = strings.Cut(action, "#")
+
+	if len(action) == 0 {
+		action = page
+	}
+	if !strings.HasPrefix(action, "/") || strings.HasPrefix(action, "//") {
+		return Form{}, false
+	}
+
+	form := Form{Name: marker, Action: cleanAction(action), Page: page, Fields: []string{}}
+
+	if len(form.Name) == 0 {
+		form.Name, _ = attribute(node, "name")
+	}
+	if len(form.Name) == 0 {
+		form.Name = form.Action
+	}
+
+	if redirect, found := attribute(node, FormRedirectAttribute); found && strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") {
+		form.Redirect = redirect
+	}
+
+	fields := map[string]bool{}
+
+	var visit func(child *html.Node)
+	visit = func(child *html.Node) {
+		if child.Type == html.ElementNode && (child.Data == "input" || child.Data == "textarea" || child.Data == "select") {
+			name, _ := attribute(child, "name")
+			inputType, _ := attribute(child, "type")
+
+			if len(name) > 0 && !fields[name] && name != HoneypotField && inputType != "submit" && inputType != "file" {
+				fields[name] = true
+				form.Fields = append(form.Fields, name)
+			}
+		}
+
+		for next := child.FirstChild; next != nil; next = next.NextSibling {
+			visit(next)
+		}
+	}
+
+	visit(node)
+
+	return form, true
+}
+
+func attribute(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return strings.TrimSpace(attr.Val), true
+		}
+	}
+
+	return "", false
+}
+
+// pagePath is the url a html file is served at, `about.html` is /about and `blog/index.html` is /blog/
+func pagePath(relPath string) string {
+	urlPath := "/" + filepath.ToSlash(relPath)
+
+	if strings.HasSuffix(urlPath, "/index.html") {
+		return strings.TrimSuffix(urlPath, "index.html")
+	}
+
+	return strings.TrimSuffix(urlPath, ".html")
+}
+
+// cleanAction matches how the static server cleans request paths
+func cleanAction(action string) string {
+	cleaned := path.Clean(action)
+
+	if strings.HasSuffix(action, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
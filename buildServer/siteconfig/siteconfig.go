@@ -0,0 +1,398 @@
+package siteconfig
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	RedirectsFile = "_redirects"
+	HeadersFile   = "_headers"
+	ConfigFile    = "deployio.json"
+
+	maxRules = 1000
+)
+
+type Rule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+	Force  bool   `json:"force"`
+}
+
+type HeaderRule struct {
+	For    string            `json:"for"`
+	Values map[string]string `json:"values"`
+}
+
+// Function maps a route under /api to a WASI module stored next to the build's files
+type Function struct {
+	Route  string `json:"route"`
+	Object string `json:"object"`
+}
+
+type SiteConfig struct {
+	Redirects []Rule       `json:"redirects"`
+	Headers   []HeaderRule `json:"headers"`
+	Forms     []Form       `json:"forms"`
+	Functions []Function   `json:"functions"`
+}
+
+// shape of deployio.json committed in the repository
+type projectFile struct {
+	Redirects []struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Permanent   *bool  `json:"permanent"`
+		Status      int    `json:"status"`
+		Force       bool   `json:"force"`
+	} `json:"redirects"`
+	Rewrites []struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Force       bool   `json:"force"`
+	} `json:"rewrites"`
+	Headers []struct {
+		Source  string            `json:"source"`
+		Headers map[string]string `json:"headers"`
+	} `json:"headers"`
+}
+
+var placeholderRegex = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+
+var headerNameRegex = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// headers owned by the static server, a site can not override them
+var reservedHeaders = map[string]bool{
+	"content-length":    true,
+	"content-encoding":  true,
+	"transfer-encoding": true,
+	"connection":        true,
+	"set-cookie":        true,
+	"location":          true,
+}
+
+// Load reads deployio.json from the project directory along with _redirects and _headers from the output folder,
+// validates every rule and returns the merged configuration, deployio.json rules come first.
+// Forms marked with data-deployio in the output's html files are collected as well.
+func Load(projectDir, outputDir string) (*SiteConfig, error) {
+	var config SiteConfig
+
+	fileRules, fileHeaders, fileErr := readProjectFile(filepath.Join(projectDir, ConfigFile))
+	if fileErr != nil {
+		return nil, fileErr
+	}
+
+	redirectRules, redirectsErr := readRedirectsFile(filepath.Join(outputDir, RedirectsFile))
+	if redirectsErr != nil {
+		return nil, redirectsErr
+	}
+
+	headerRules, headersErr := readHeadersFile(filepath.Join(outputDir, HeadersFile))
+	if headersErr != nil {
+		return nil, headersErr
+	}
+
+	config.Redirects = append(fileRules, redirectRules...)
+	config.Headers = append(fileHeaders, headerRules...)
+
+	if len(config.Redirects) > maxRules {
+		return nil, fmt.Errorf("[CONFIG] %d redirect rules found, at most %d are allowed", len(config.Redirects), maxRules)
+	}
+
+	for index, rule := range config.Redirects {
+		if err := validateRule(rule); err != nil {
+			return nil, fmt.Errorf("[CONFIG] rule %d (%s -> %s): %v", index+1, rule.From, rule.To, err)
+		}
+	}
+
+	if len(config.Headers) > maxRules {
+		return nil, fmt.Errorf("[CONFIG] %d header rules found, at most %d are allowed", len(config.Headers), maxRules)
+	}
+
+	for _, rule := range config.Headers {
+		if err := validateHeaderRule(rule); err != nil {
+			return nil, fmt.Errorf("[CONFIG] headers for %s: %v", rule.For, err)
+		}
+	}
+
+	forms, formsErr := scanForms(outputDir)
+	if formsErr != nil {
+		return nil, formsErr
+	}
+
+	config.Forms = forms
+
+	return &config, nil
+}
+
+// IsConfigFile reports whether the path relative to the output folder is consumed at build time and must not be served
+func IsConfigFile(relPath string) bool {
+	relPath = strings.TrimPrefix(filepath.ToSlash(relPath), "/")
+
+	return relPath == RedirectsFile || relPath == HeadersFile
+}
+
+func readProjectFile(path string) ([]Rule, []HeaderRule, error) {
+	content, readErr := os.ReadFile(path)
+	if os.IsNotExist(readErr) {
+		return nil, nil, nil
+	}
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+
+	var file projectFile
+
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, nil, fmt.Errorf("[CONFIG] %s is not valid json: %v", ConfigFile, err)
+	}
+
+	var rules []Rule
+
+	for _, redirect := range file.Redirects {
+		status := redirect.Status
+		if status == 0 {
+			status = 301
+			if redirect.Permanent != nil && !*redirect.Permanent {
+				status = 302
+			}
+		}
+
+		rules = append(rules, Rule{From: redirect.Source, To: redirect.Destination, Status: status, Force: redirect.Force})
+	}
+
+	for _, rewrite := range file.Rewrites {
+		rules = append(rules, Rule{From: rewrite.Source, To: rewrite.Destination, Status: 200, Force: rewrite.Force})
+	}
+
+	var headers []HeaderRule
+
+	for _, header := range file.Headers {
+		headers = append(headers, HeaderRule{For: header.Source, Values: header.Headers})
+	}
+
+	return rules, headers, nil
+}
+
+// parses the netlify style `from to [status][!]` format, one rule per line
+func readRedirectsFile(path string) ([]Rule, error) {
+	file, openErr := os.Open(path)
+	if os.IsNotExist(openErr) {
+		return nil, nil
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	defer file.Close()
+
+	var rules []Rule
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("[CONFIG] %s line %d: expected `from to [status]`", RedirectsFile, lineNumber)
+		}
+
+		rule := Rule{From: fields[0], To: fields[1], Status: 301}
+
+		if len(fields) == 3 {
+			status := fields[2]
+			if strings.HasSuffix(status, "!") {
+				rule.Force = true
+				status = strings.TrimSuffix(status, "!")
+			}
+
+			code, convErr := strconv.Atoi(status)
+			if convErr != nil {
+				return nil, fmt.Errorf("[CONFIG] %s line %d: invalid status %s", RedirectsFile, lineNumber, fields[2])
+			}
+
+			rule.Status = code
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// parses the netlify style _headers file, a path pattern followed by indented `Name: value` lines
+func readHeadersFile(path string) ([]HeaderRule, error) {
+	file, openErr := os.Open(path)
+	if os.IsNotExist(openErr) {
+		return nil, nil
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	defer file.Close()
+
+	var rules []HeaderRule
+	var current *HeaderRule
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(raw, " ") && !strings.HasPrefix(raw, "\t") {
+			rules = append(rules, HeaderRule{For: line, Values: map[string]string{}})
+			current = &rules[len(rules)-1]
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("[CONFIG] %s line %d: header given before any path", HeadersFile, lineNumber)
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("[CONFIG] %s line %d: expected `Name: value`", HeadersFile, lineNumber)
+		}
+
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		// repeated headers are joined the same way http does it
+		if existing, exists := current.Values[name]; exists {
+			value = existing + ", " + value
+		}
+
+		current.Values[name] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func validateHeaderRule(rule HeaderRule) error {
+	if err := validatePattern(rule.For); err != nil {
+		return err
+	}
+
+	for name, value := range rule.Values {
+		if !headerNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+
+		if reservedHeaders[strings.ToLower(name)] {
+			return fmt.Errorf("%s can not be set by a site", name)
+		}
+
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("value of %s contains a line break", name)
+		}
+	}
+
+	return nil
+}
+
+func validateRule(rule Rule) error {
+	switch rule.Status {
+	case 200, 301, 302, 307, 308:
+	default:
+		return fmt.Errorf("status %d is not supported, use 200, 301, 302, 307 or 308", rule.Status)
+	}
+
+	if err := validatePattern(rule.From); err != nil {
+		return err
+	}
+
+	placeholders := map[string]bool{}
+
+	for _, segment := range strings.Split(strings.Trim(rule.From, "/"), "/") {
+		if segment == "*" {
+			placeholders["splat"] = true
+		} else if strings.HasPrefix(segment, ":") {
+			placeholders[segment[1:]] = true
+		}
+	}
+
+	isExternal := strings.HasPrefix(rule.To, "http://") || strings.HasPrefix(rule.To, "https://")
+
+	if !isExternal && !strings.HasPrefix(rule.To, "/") {
+		return fmt.Errorf("destination must start with / or be an absolute http(s) url")
+	}
+
+	// a placeholder in the scheme or host would let every request pick the server the site proxies to
+	if isExternal && placeholderRegex.MatchString(externalOrigin(rule.To)) {
+		return fmt.Errorf("placeholders are only allowed in the path of an external destination")
+	}
+
+	// ports never match since placeholders have to start with a letter
+	for _, placeholder := range placeholderRegex.FindAllString(rule.To, -1) {
+		if !placeholders[placeholder[1:]] {
+			return fmt.Errorf("destination uses %s which is not captured by the source", placeholder)
+		}
+	}
+
+	return nil
+}
+
+// externalOrigin is the scheme and host of an absolute url, everything before the path, query or fragment
+func externalOrigin(destination string) string {
+	scheme, rest, _ := strings.Cut(destination, "://")
+
+	if end := strings.IndexAny(rest, "/?#"); end != -1 {
+		rest = rest[:end]
+	}
+
+	return scheme + "://" + rest
+}
+
+// validatePattern checks a path pattern made of literal segments, `:name` placeholders and a trailing `*`
+func validatePattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("source must start with /")
+	}
+
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+
+	for index, segment := range segments {
+		if strings.Contains(segment, "*") {
+			if segment != "*" || index != len(segments)-1 {
+				return fmt.Errorf("* is only allowed as the last segment of the source")
+			}
+			continue
+		}
+
+		if strings.HasPrefix(segment, ":") && placeholderRegex.FindString(segment) != segment {
+			return fmt.Errorf("invalid placeholder %s", segment)
+		}
+	}
+
+	return nil
+}
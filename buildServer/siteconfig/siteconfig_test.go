@@ -0,0 +1,212 @@
+package siteconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// site writes the files into a project directory with the output folder dist and loads its config
+func site(t *testing.T, files map[string]string) (*SiteConfig, error) {
+	t.Helper()
+
+	projectDir := t.TempDir()
+	outputDir := filepath.Join(projectDir, "dist")
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return Load(projectDir, outputDir)
+}
+
+func TestLoadRedirects(t *testing.T) {
+	config, err := site(t, map[string]string{
+		ConfigFile: `{
+			"redirects": [
+				{"source": "/old", "destination": "/new"},
+				{"source": "/temp", "destination": "/elsewhere", "permanent": false},
+				{"source": "/moved", "destination": "/there", "status": 308, "force": true}
+			],
+			"rewrites": [
+				{"source": "/app/*", "destination": "/app/index.html"}
+			]
+		}`,
+		"dist/" + RedirectsFile: strings.Join([]string{
+			"# comments and empty lines are skipped",
+			"",
+			"/blog/:year/:slug   /posts/:slug?year=:year   302",
+			"/docs/*             /documentation/:splat",
+			"/api/*              https://api.example.com/:splat   200!",
+		}, "\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deployio.json rules come first, in the order they were written
+	want := []Rule{
+		{From: "/old", To: "/new", Status: 301},
+		{From: "/temp", To: "/elsewhere", Status: 302},
+		{From: "/moved", To: "/there", Status: 308, Force: true},
+		{From: "/app/*", To: "/app/index.html", Status: 200},
+		{From: "/blog/:year/:slug", To: "/posts/:slug?year=:year", Status: 302},
+		{From: "/docs/*", To: "/documentation/:splat", Status: 301},
+		{From: "/api/*", To: "https://api.example.com/:splat", Status: 200, Force: true},
+	}
+
+	if !reflect.DeepEqual(config.Redirects, want) {
+		t.Errorf("redirects = %+v\nwant %+v", config.Redirects, want)
+	}
+}
+
+func TestLoadRefusesInvalidRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		redirects string
+		message   string
+	}{
+		{"unsupported status", "/a /b 404", "status 404 is not supported"},
+		{"status that is not a number", "/a /b moved", "invalid status moved"},
+		{"missing destination", "/a", "expected `from to [status]`"},
+		{"too many fields", "/a /b 301 extra", "expected `from to [status]`"},
+		{"relative source", "a /b", "source must start with /"},
+		{"relative destination", "/a b", "destination must start with /"},
+		{"splat in the middle", "/a/*/b /c", "* is only allowed as the last segment"},
+		{"splat inside a segment", "/a/b* /c", "* is only allowed as the last segment"},
+		{"invalid placeholder", "/a/:1st /c", "invalid placeholder :1st"},
+		{"placeholder not captured", "/a/:id /b/:slug", "destination uses :slug"},
+		{"splat not captured", "/a/:id /b/:splat", "destination uses :splat"},
+		{"placeholder in the host", "/p/:host https://:host.example.com/", "placeholders are only allowed in the path"},
+		{"placeholder in the scheme", "/p/:scheme :scheme://example.com/", "destination must start with /"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := site(t, map[string]string{"dist/" + RedirectsFile: test.redirects})
+
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("err = %v, want it to mention %q", err, test.message)
+			}
+		})
+	}
+}
+
+func TestLoadAcceptsPorts(t *testing.T) {
+	if _, err := site(t, map[string]string{"dist/" + RedirectsFile: "/api/* http://localhost:8080/:splat 200"}); err != nil {
+		t.Errorf("a port was taken for a placeholder: %v", err)
+	}
+}
+
+func TestLoadRefusesInvalidConfigFile(t *testing.T) {
+	_, err := site(t, map[string]string{ConfigFile: `{"redirects": [`})
+	if err == nil || !strings.Contains(err.Error(), "is not valid json") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestLoadLimitsRules(t *testing.T) {
+	rules := strings.Repeat("/a /b\n", maxRules+1)
+
+	_, err := site(t, map[string]string{"dist/" + RedirectsFile: rules})
+	if err == nil || !strings.Contains(err.Error(), "redirect rules found") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	config, err := site(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Redirects) != 0 || len(config.Headers) != 0 {
+		t.Errorf("config = %+v, want it empty", config)
+	}
+}
+
+func TestIsConfigFile(t *testing.T) {
+	tests := map[string]bool{
+		"_redirects":        true,
+		"/_headers":         true,
+		"docs/_redirects":   false,
+		"deployio.json":     false,
+		"_redirects.backup": false,
+	}
+
+	for path, want := range tests {
+		if got := IsConfigFile(path); got != want {
+			t.Errorf("IsConfigFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestLoadHeaders(t *testing.T) {
+	config, err := site(t, map[string]string{
+		ConfigFile: `{"headers": [{"source": "/*", "headers": {"X-Frame-Options": "DENY"}}]}`,
+		"dist/" + HeadersFile: strings.Join([]string{
+			"/assets/*",
+			"  Access-Control-Allow-Origin: *",
+			"  Link: </a.css>; rel=preload",
+			"\tLink: </b.css>; rel=preload",
+			"# a comment",
+			"/embed/:id",
+			"  X-Frame-Options: SAMEORIGIN",
+		}, "\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HeaderRule{
+		{For: "/*", Values: map[string]string{"X-Frame-Options": "DENY"}},
+		{For: "/assets/*", Values: map[string]string{"Access-Control-Allow-Origin": "*", "Link": "</a.css>; rel=preload, </b.css>; rel=preload"}},
+		{For: "/embed/:id", Values: map[string]string{"X-Frame-Options": "SAMEORIGIN"}},
+	}
+
+	if !reflect.DeepEqual(config.Headers, want) {
+		t.Errorf("headers = %+v\nwant %+v", config.Headers, want)
+	}
+}
+
+func TestLoadRefusesInvalidHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		message string
+	}{
+		{"content length", "/*\n  Content-Length: 0", "Content-Length can not be set by a site"},
+		{"set cookie in any case", "/*\n  set-cookie: session=1", "set-cookie can not be set by a site"},
+		{"location", "/*\n  Location: https://example.com", "Location can not be set by a site"},
+		{"transfer encoding", "/*\n  Transfer-Encoding: chunked", "Transfer-Encoding can not be set by a site"},
+		{"invalid name", "/*\n  X Frame: DENY", `invalid header name "X Frame"`},
+		{"header before a path", "  X-Frame-Options: DENY", "header given before any path"},
+		{"missing colon", "/*\n  X-Frame-Options DENY", "expected `Name: value`"},
+		{"relative path", "assets/*\n  X-Frame-Options: DENY", "source must start with /"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := site(t, map[string]string{"dist/" + HeadersFile: test.headers})
+
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("err = %v, want it to mention %q", err, test.message)
+			}
+		})
+	}
+}
+
+func TestLoadRefusesLineBreakInHeader(t *testing.T) {
+	_, err := site(t, map[string]string{ConfigFile: `{"headers": [{"source": "/*", "headers": {"X-Test": "a\r\nSet-Cookie: b"}}]}`})
+	if err == nil || !strings.Contains(err.Error(), "contains a line break") {
+		t.Errorf("err = %v", err)
+	}
+}
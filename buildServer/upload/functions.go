@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"buildServer/siteconfig"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// folder of the project holding the functions, `api/hello.wasm` answers /api/hello
+	FunctionsFolder = "api"
+
+	MaxFunctions    = 50
+	maxFunctionSize = 10 << 20
+)
+
+// FunctionsPrefix is where the modules of a build live, apart from its files so they are never served as assets
+func FunctionsPrefix(buildId int) string {
+	return fmt.Sprintf("_functions/%d/", buildId)
+}
+
+// UploadFunctions uploads every .wasm module found in the project's api folder and returns their routes.
+// `api/users/index.wasm` answers /api/users. Every module is checked before the first one is uploaded.
+func UploadFunctions(buildId int, projectDir string) ([]siteconfig.Function, error) {
+	functionsDir := filepath.Join(projectDir, FunctionsFolder)
+
+	files, walkErr := getFilePaths(functionsDir)
+	if os.IsNotExist(walkErr) {
+		return nil, nil
+	}
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	var functions []siteconfig.Function
+	var modules []string
+
+	for _, file := range files {
+		if filepath.Ext(file) != ".wasm" {
+			continue
+		}
+
+		if len(functions) == MaxFunctions {
+			return nil, fmt.Errorf("[FUNCTIONS] more than %d functions found", MaxFunctions)
+		}
+
+		info, statErr := os.Stat(file)
+		if statErr != nil {
+			return nil, statErr
+		}
+
+		relPath, relErr := filepath.Rel(functionsDir, file)
+		if relErr != nil {
+			return nil, relErr
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if info.Size() > maxFunctionSize {
+			return nil, fmt.Errorf("[FUNCTIONS] %s is larger than %d bytes", relPath, maxFunctionSize)
+		}
+
+		route := "/" + FunctionsFolder + "/" + strings.TrimSuffix(relPath, ".wasm")
+		route = strings.TrimSuffix(route, "/index")
+
+		functions = append(functions, siteconfig.Function{Route: route, Object: FunctionsPrefix(buildId) + relPath})
+		modules = append(modules, file)
+	}
+
+	for index, file := range modules {
+		if err := uploadFile(functions[index].Object, file); err != nil {
+			return nil, err
+		}
+	}
+
+	return functions, nil
+}
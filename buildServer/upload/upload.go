@@ -2,12 +2,15 @@ package upload
 
 import (
 	"buildServer/config"
+	"buildServer/mimetypes"
+	"buildServer/siteconfig"
 	"buildServer/utils"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/minio/minio-go/v7"
@@ -21,11 +24,6 @@ func UploadProjectFiles(buildId int, userId int, workingDir string) error {
 		log.Fatalln("[UPLOAD] " + queryErr.Error())
 	}
 
-	proDelErr := deleteExistingFiles(projectName)
-	if proDelErr != nil {
-		return proDelErr
-	}
-
 	var srcFolder string
 	if directory != "./" {
 		if outputFolder[0] != '/' {
@@ -51,13 +49,23 @@ func UploadProjectFiles(buildId int, userId int, workingDir string) error {
 		// Remove "outputFolder" and everything before it
 		relPath := file[index+len(outputFolder):]
 
-		destPath := filepath.Join(projectName, relPath)
+		if siteconfig.IsConfigFile(relPath) {
+			continue
+		}
+
+		destPath := filepath.Join(BuildPrefix(buildId), relPath)
 		err := uploadFile(destPath, file)
 		if err != nil {
 			return err
 		}
 	}
 
+	keptQuery := `UPDATE "deploy-io".builds SET artifacts_kept = true WHERE id = $1`
+	_, keptErr := config.DataBase.Exec(keptQuery, buildId)
+	if keptErr != nil {
+		return keptErr
+	}
+
 	delErr := utils.DeleteDirectory(getCurDir() + "/tmp/" + workingDir)
 	if delErr != nil {
 		return delErr
@@ -78,6 +86,79 @@ func UploadProjectFiles(buildId int, userId int, workingDir string) error {
 	return nil
 }
 
+// BuildPrefix is where the files of a build live, the underscore keeps it apart from project names
+func BuildPrefix(buildId int) string {
+	return fmt.Sprintf("_builds/%d/", buildId)
+}
+
+// PruneArtifacts removes files of the project's older builds, keeping the newest BUILD_RETENTION builds
+// and every build that is deployed or is a canary. Files from before per build prefixes existed are removed as well.
+func PruneArtifacts(projectId int) error {
+	var projectName string
+	nameQuery := `SELECT name FROM "deploy-io".projects WHERE id = $1`
+	nameErr := config.DataBase.QueryRow(nameQuery, projectId).Scan(&projectName)
+	if nameErr != nil {
+		return nameErr
+	}
+
+	legacyErr := deleteExistingFiles(projectName + "/")
+	if legacyErr != nil {
+		return legacyErr
+	}
+
+	query := `
+		SELECT b.id FROM "deploy-io".builds b
+		WHERE b.project_id = $1 AND b.artifacts_kept = true
+		AND NOT EXISTS (SELECT 1 FROM "deploy-io".deployments d WHERE d.build_id = b.id AND d.status = true)
+		AND NOT EXISTS (SELECT 1 FROM "deploy-io".projects p WHERE p.canary_build_id = b.id)
+		ORDER BY b.id DESC OFFSET $2;
+	`
+	rows, queryErr := config.DataBase.Query(query, projectId, getBuildRetention())
+	if queryErr != nil {
+		return queryErr
+	}
+
+	var buildIds []int
+
+	for rows.Next() {
+		var buildId int
+		if err := rows.Scan(&buildId); err != nil {
+			rows.Close()
+			return err
+		}
+		buildIds = append(buildIds, buildId)
+	}
+	rows.Close()
+
+	for _, buildId := range buildIds {
+		if err := deleteExistingFiles(BuildPrefix(buildId)); err != nil {
+			return err
+		}
+
+		if err := deleteExistingFiles(FunctionsPrefix(buildId)); err != nil {
+			return err
+		}
+
+		updateQuery := `UPDATE "deploy-io".builds SET artifacts_kept = false WHERE id = $1`
+		if _, err := config.DataBase.Exec(updateQuery, buildId); err != nil {
+			return err
+		}
+
+		log.Printf("[UPLOAD] Pruned files of build %d\n", buildId)
+	}
+
+	return nil
+}
+
+func getBuildRetention() int {
+	retention, convErr := strconv.Atoi(os.Getenv("BUILD_RETENTION"))
+	if convErr != nil || retention < 1 {
+		return 5
+	}
+
+	return retention
+}
+
 func uploadFile(objectName string, filePath string) error {
 	bucketName, bucketExists := os.LookupEnv("MIO_BUCKET")
 	if !bucketExists {
@@ -86,11 +167,12 @@ func uploadFile(objectName string, filePath string) error {
 
 	ctx := context.Background()
 
-	// Upload the test file
-	// Change the value of filePath if the file is in another location
-	contentType := "application/octet-stream"
+	// staticServer serves the stored content type as is, so it has to be right at upload time
+	contentType := mimetypes.TypeByExtension(filepath.Ext(filePath))
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
 
-	// Upload the test file with FPutObject
 	_, err := config.Minio.FPutObject(ctx, bucketName, objectName, filePath, minio.PutObjectOptions{ContentType: contentType})
 	if err != nil {
 		return err
@@ -127,14 +209,14 @@ func getCurDir() string {
 	return cwd
 }
 
-func deleteExistingFiles(projectName string) error {
+func deleteExistingFiles(prefix string) error {
 	bucketName, bucketExists := os.LookupEnv("MIO_BUCKET")
 	if !bucketExists {
 		return fmt.Errorf("[UPLOAD] bucket name was not set in env variable")
 	}
 
 	var objects []minio.ObjectInfo
-	for object := range config.Minio.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{Prefix: projectName, Recursive: true}) {
+	for object := range config.Minio.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
 		if object.Err != nil {
 			return object.Err
 		}
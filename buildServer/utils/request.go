@@ -8,8 +8,11 @@ import (
 )
 
 func Request(method, url string, headers, params *map[string]string, body *[]byte) (*http.Response, error) {
-	client := &http.Client{}
+	return RequestWith(&http.Client{}, method, url, headers, params, body)
+}
 
+// RequestWith is Request sent through the given client
+func RequestWith(client *http.Client, method, url string, headers, params *map[string]string, body *[]byte) (*http.Response, error) {
 	url = addParamsToURL(url, params)
 
 	var req *http.Request
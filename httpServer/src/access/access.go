@@ -0,0 +1,103 @@
+package access
+
+import (
+	"database/sql"
+	"fmt"
+	"httpServer/config"
+	"httpServer/utils"
+	"net/http"
+)
+
+type Role string
+
+// every role can do what the roles before it can
+const (
+	RoleViewer    Role = "viewer"
+	RoleDeveloper Role = "developer"
+	RoleAdmin     Role = "admin"
+	RoleOwner     Role = "owner"
+)
+
+var ranks = map[Role]int{
+	RoleViewer:    1,
+	RoleDeveloper: 2,
+	RoleAdmin:     3,
+	RoleOwner:     4,
+}
+
+func (r Role) Valid() bool {
+	_, found := ranks[r]
+	return found
+}
+
+// Includes tells whether the role allows at least what the required role does
+func (r Role) Includes(required Role) bool {
+	return ranks[r] >= ranks[required]
+}
+
+// ProjectRole returns the user's role on a project, the owner of a personal project is its user
+// and members of the owning organization have their membership role. Without access it is empty.
+func ProjectRole(projectId any, userId int) (Role, error) {
+	var role sql.NullString
+
+	query := `
+		SELECT CASE WHEN p.org_id IS NULL THEN CASE WHEN p.user_id = $2 THEN 'owner' END ELSE m.role END
+		FROM "deploy-io".projects p
+		LEFT JOIN "deploy-io".org_memberships m ON m.org_id = p.org_id AND m.user_id = $2
+		WHERE p.id = $1;
+	`
+	err := config.DataBase.QueryRow(query, projectId, userId).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return Role(role.String), nil
+}
+
+// OrgRole returns the user's role in an organization, empty when the user is not a member
+func OrgRole(orgId any, userId int) (Role, error) {
+	var role string
+
+	query := `SELECT m.role FROM "deploy-io".org_memberships m WHERE m.org_id = $1 AND m.user_id = $2`
+	err := config.DataBase.QueryRow(query, orgId, userId).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return Role(role), nil
+}
+
+// Project checks that the user holds at least the required role on the project and writes the error response when not.
+// Projects the user can not see are reported as missing so their ids can not be probed.
+func Project(w http.ResponseWriter, projectId any, userId int, required Role) bool {
+	role, err := ProjectRole(projectId, userId)
+
+	return check(w, role, err, required, "project")
+}
+
+// Org is the organization counterpart of Project
+func Org(w http.ResponseWriter, orgId any, userId int, required Role) bool {
+	role, err := OrgRole(orgId, userId)
+
+	return check(w, role, err, required, "organization")
+}
+
+func check(w http.ResponseWriter, role Role, err error, required Role, resource string) bool {
+	if err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return false
+	}
+
+	if len(role) == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return false
+	}
+
+	if !role.Includes(required) {
+		errMsg := fmt.Sprintf("the %s role can not do this on the %s", role, resource)
+		utils.HandleError(utils.ErrForbidden, nil, w, &errMsg)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,173 @@
+package githubapp
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	auth "httpServer/src/routes/Auth"
+	"httpServer/utils"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// ErrNotInstalled is returned when the GitHub App is not configured or not installed on the repository
+var ErrNotInstalled = errors.New("[APP] GitHub App is not installed on the repository")
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// installation tokens are cached per repository until shortly before GitHub expires them
+var installationTokens = map[int]installationToken{}
+var installationTokensMutex sync.Mutex
+
+// RepositoryToken returns a token that can read the repository, the GitHub App's installation token when the app
+// is installed on it, otherwise the OAuth token of the user who connected the project
+func RepositoryToken(githubId int, userId int) (string, error) {
+	token, err := InstallationToken(githubId)
+	if err == nil {
+		return token, nil
+	}
+
+	if err != ErrNotInstalled {
+		fmt.Println("[APP] " + err.Error() + ", falling back to the user's token")
+	}
+
+	accessToken, accessErr := auth.GetAccessToken(userId)
+	if accessErr != nil {
+		return "", accessErr
+	}
+
+	return *accessToken, nil
+}
+
+// InstallationToken returns a token of the GitHub App installation covering the repository, limited to that repository
+func InstallationToken(githubId int) (string, error) {
+	installationTokensMutex.Lock()
+	cached, found := installationTokens[githubId]
+	installationTokensMutex.Unlock()
+
+	if found && time.Now().Before(cached.expiresAt.Add(-time.Minute)) {
+		return cached.token, nil
+	}
+
+	appToken, appErr := appJWT()
+	if appErr != nil {
+		return "", appErr
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + appToken,
+	}
+
+	var installation struct {
+		Id int `json:"id"`
+	}
+
+	statusCode, installationErr := requestJSON("GET", fmt.Sprintf("https://api.github.com/repositories/%d/installation", githubId), headers, nil, &installation)
+	if statusCode == http.StatusNotFound {
+		return "", ErrNotInstalled
+	}
+	if installationErr != nil {
+		return "", installationErr
+	}
+
+	body, constructorErr := json.Marshal(map[string][]int{"repository_ids": {githubId}})
+	if constructorErr != nil {
+		return "", constructorErr
+	}
+
+	var accessToken struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	_, tokenErr := requestJSON("POST", fmt.Sprintf("https://api.github.com/app/installations/%d/access_tokens", installation.Id), headers, &body, &accessToken)
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	installationTokensMutex.Lock()
+	installationTokens[githubId] = installationToken{token: accessToken.Token, expiresAt: accessToken.ExpiresAt}
+	installationTokensMutex.Unlock()
+
+	return accessToken.Token, nil
+}
+
+func requestJSON(method, url string, headers map[string]string, body *[]byte, response any) (int, error) {
+	resp, err := utils.Request(method, url, &headers, nil, body)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return resp.StatusCode, readErr
+	}
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("[APP] GitHub answered %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return resp.StatusCode, json.Unmarshal(respBody, response)
+}
+
+// appJWT signs the short lived RS256 JWT a GitHub App authenticates as itself with, GitHub allows at most ten minutes
+func appJWT() (string, error) {
+	appId := strings.TrimSpace(os.Getenv("GH_APP_ID"))
+	if len(appId) == 0 {
+		return "", ErrNotInstalled
+	}
+
+	key, keyErr := appPrivateKey()
+	if keyErr != nil {
+		return "", keyErr
+	}
+
+	// issued a minute early to allow for clock drift between us and GitHub
+	claims := map[string]interface{}{"iss": appId}
+	jwtauth.SetIssuedAt(claims, time.Now().Add(-time.Minute))
+	jwtauth.SetExpiryIn(claims, 9*time.Minute)
+
+	_, tokenStr, err := jwtauth.New("RS256", key, nil).Encode(claims)
+
+	return tokenStr, err
+}
+
+// appPrivateKey reads the PEM key GitHub generated for the app, GH_APP_PRIVATE_KEY holds it with `\n` for line breaks
+func appPrivateKey() (*rsa.PrivateKey, error) {
+	encoded := strings.ReplaceAll(os.Getenv("GH_APP_PRIVATE_KEY"), `\n`, "\n")
+
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("[APP] GH_APP_PRIVATE_KEY is not a PEM encoded key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, isRSA := parsed.(*rsa.PrivateKey)
+	if !isRSA {
+		return nil, fmt.Errorf("[APP] GH_APP_PRIVATE_KEY is not an RSA key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,101 @@
+package gitprovider
+
+import (
+	"context"
+	"fmt"
+	"httpServer/src/outbound"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Git reads public repositories over https with the git client, for hosts that have no api.
+// It has nothing to list repositories with and no webhooks, its projects are built by hand
+type Git struct{}
+
+func (Git) ListRepositories(repo Repo) ([]Repository, error) {
+	return nil, ErrUnsupported
+}
+
+func (Git) ResolveRef(repo Repo, ref string) (string, error) {
+	if err := ValidGitURL(repo.Id); err != nil {
+		return "", err
+	}
+
+	// patterns match the end of a ref name, spelling them out keeps `main` from matching `feature/main`
+	patterns := []string{"HEAD"}
+	if len(ref) > 0 {
+		patterns = []string{"refs/heads/" + ref, "refs/tags/" + ref}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	args, remoteErr := remoteOptions(repo.Id)
+	if remoteErr != nil {
+		return "", remoteErr
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append(append(args, "ls-remote", repo.Id), patterns...)...)
+	// a repository that asks for credentials is not public, fail instead of waiting for a prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("[GIT] could not read %s: %s", repo.Id, err.Error())
+	}
+
+	// every line is `<sha>\t<ref>` sorted by ref, so a branch comes before a tag of the same name
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		// a full sha is not advertised by the server, the build fetches it directly
+		if len(ref) == 40 {
+			return ref, nil
+		}
+		return "", fmt.Errorf("[GIT] %s has no ref %q", repo.Id, ref)
+	}
+
+	return fields[0], nil
+}
+
+func (Git) WebURL(repo Repo) (string, error) {
+	return strings.TrimSuffix(repo.Id, ".git"), nil
+}
+
+func (Git) VerifyWebhook(r *http.Request, body []byte, secret string) (*Push, error) {
+	return nil, ErrUnsupported
+}
+
+// ValidGitURL accepts https urls without credentials, they would end up in the database and the build logs.
+// The host has to resolve to public addresses only
+func ValidGitURL(repoURL string) error {
+	parsed, err := url.Parse(repoURL)
+	if err != nil || parsed.Scheme != "https" || len(parsed.Host) == 0 || parsed.User != nil || len(strings.Trim(parsed.Path, "/")) == 0 {
+		return fmt.Errorf("[GIT] repository has to be an https url without credentials")
+	}
+
+	if err := outbound.CheckURL(repoURL); err != nil {
+		return fmt.Errorf("[GIT] repository has to be on a public address")
+	}
+
+	return nil
+}
+
+// remoteOptions are the git options for talking to the repository: the host is pinned to the public address
+// it was checked at, redirects are not followed and nothing but https is spoken
+func remoteOptions(repoURL string) ([]string, error) {
+	resolve, err := outbound.CurlResolve(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("[GIT] repository has to be on a public address")
+	}
+
+	return []string{
+		"-c", "http.curloptResolve=" + resolve,
+		"-c", "http.followRedirects=false",
+		"-c", "protocol.allow=never",
+		"-c", "protocol.https.allow=always",
+	}, nil
+}
@@ -0,0 +1,130 @@
+package gitprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type Gitea struct{}
+
+func (Gitea) ListRepositories(repo Repo) ([]Repository, error) {
+	if len(repo.Token) == 0 {
+		return nil, fmt.Errorf("[GIT] listing Gitea repositories needs an access token")
+	}
+
+	api, headers, err := giteaAPI(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []struct {
+		Id       int    `json:"id"`
+		FullName string `json:"full_name"`
+		HtmlURL  string `json:"html_url"`
+		Owner    struct {
+			AvatarURL string `json:"avatar_url"`
+		} `json:"owner"`
+	}
+
+	if err := requestJSON(api+"/user/repos", headers, map[string]string{"limit": "50"}, &response); err != nil {
+		return nil, err
+	}
+
+	// Gitea addresses repositories by owner/name, which is what projects store
+	repositories := []Repository{}
+	for _, repository := range response {
+		repositories = append(repositories, Repository{
+			Id:        repository.FullName,
+			FullName:  repository.FullName,
+			AvatarURL: repository.Owner.AvatarURL,
+			URL:       repository.HtmlURL,
+		})
+	}
+
+	return repositories, nil
+}
+
+func (Gitea) ResolveRef(repo Repo, ref string) (string, error) {
+	api, headers, err := giteaAPI(repo)
+	if err != nil {
+		return "", err
+	}
+
+	// without sha Gitea lists the commits of the default branch
+	params := map[string]string{"limit": "1", "stat": "false"}
+	if len(ref) > 0 {
+		params["sha"] = ref
+	}
+
+	var commits []struct {
+		Sha string `json:"sha"`
+	}
+
+	if err := requestJSON(api+"/repos/"+repo.Id+"/commits", headers, params, &commits); err != nil {
+		return "", err
+	}
+
+	if len(commits) == 0 {
+		return "", fmt.Errorf("[GIT] No commits have been made")
+	}
+
+	return commits[0].Sha, nil
+}
+
+func (Gitea) WebURL(repo Repo) (string, error) {
+	api, headers, err := giteaAPI(repo)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		HtmlURL string `json:"html_url"`
+	}
+
+	if err := requestJSON(api+"/repos/"+repo.Id, headers, nil, &response); err != nil {
+		return "", err
+	}
+
+	return response.HtmlURL, nil
+}
+
+// VerifyWebhook checks the X-Gitea-Signature header, a hex HMAC-SHA256 of the body
+func (Gitea) VerifyWebhook(r *http.Request, body []byte, secret string) (*Push, error) {
+	if !validSignature(body, secret, r.Header.Get("X-Gitea-Signature")) {
+		return nil, ErrUnverified
+	}
+
+	if r.Header.Get("X-Gitea-Event") != "push" {
+		return nil, nil
+	}
+
+	var event struct {
+		Ref        string `json:"ref"`
+		After      string `json:"after"`
+		Repository struct {
+			FullName      string `json:"full_name"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	return defaultBranchPush(event.Repository.FullName, event.Ref, event.After, event.Repository.DefaultBranch), nil
+}
+
+func giteaAPI(repo Repo) (string, map[string]string, error) {
+	host, err := HostURL(repo.Host, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	headers := map[string]string{}
+	if len(repo.Token) > 0 {
+		headers["Authorization"] = "token " + repo.Token
+	}
+
+	return host + "/api/v1", headers, nil
+}
@@ -0,0 +1,140 @@
+package gitprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"httpServer/src/githubapp"
+	auth "httpServer/src/routes/Auth"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type GitHub struct{}
+
+// ListRepositories lists what the user's OAuth token can see, the GitHub App only covers the repositories it is installed on
+func (GitHub) ListRepositories(repo Repo) ([]Repository, error) {
+	accessToken, err := auth.GetAccessToken(repo.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + *accessToken,
+	}
+
+	var response []struct {
+		Id       int    `json:"id"`
+		FullName string `json:"full_name"`
+		HtmlURL  string `json:"html_url"`
+		Owner    struct {
+			AvatarURL string `json:"avatar_url"`
+		} `json:"owner"`
+	}
+
+	if err := requestJSON("https://api.github.com/user/repos", headers, nil, &response); err != nil {
+		return nil, err
+	}
+
+	repositories := []Repository{}
+	for _, repository := range response {
+		repositories = append(repositories, Repository{
+			Id:        strconv.Itoa(repository.Id),
+			FullName:  repository.FullName,
+			AvatarURL: repository.Owner.AvatarURL,
+			URL:       repository.HtmlURL,
+		})
+	}
+
+	return repositories, nil
+}
+
+func (GitHub) ResolveRef(repo Repo, ref string) (string, error) {
+	githubId, headers, err := githubHeaders(repo)
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]string{"per_page": "1"}
+	if len(ref) > 0 {
+		params["sha"] = ref
+	}
+
+	var commits []struct {
+		Sha string `json:"sha"`
+	}
+
+	if err := requestJSON(fmt.Sprintf("https://api.github.com/repositories/%d/commits", githubId), headers, params, &commits); err != nil {
+		return "", err
+	}
+
+	if len(commits) == 0 {
+		return "", fmt.Errorf("[GIT] No commits have been made")
+	}
+
+	return commits[0].Sha, nil
+}
+
+func (GitHub) WebURL(repo Repo) (string, error) {
+	githubId, headers, err := githubHeaders(repo)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		HtmlURL string `json:"html_url"`
+	}
+
+	if err := requestJSON(fmt.Sprintf("https://api.github.com/repositories/%d", githubId), headers, nil, &response); err != nil {
+		return "", err
+	}
+
+	return response.HtmlURL, nil
+}
+
+// VerifyWebhook checks the X-Hub-Signature-256 header GitHub signs every delivery with
+func (GitHub) VerifyWebhook(r *http.Request, body []byte, secret string) (*Push, error) {
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if !strings.HasPrefix(signature, "sha256=") || !validSignature(body, secret, strings.TrimPrefix(signature, "sha256=")) {
+		return nil, ErrUnverified
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+
+	var event struct {
+		Ref        string `json:"ref"`
+		After      string `json:"after"`
+		Deleted    bool   `json:"deleted"`
+		Repository struct {
+			Id            int    `json:"id"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	if event.Deleted {
+		return nil, nil
+	}
+
+	return defaultBranchPush(strconv.Itoa(event.Repository.Id), event.Ref, event.After, event.Repository.DefaultBranch), nil
+}
+
+// githubHeaders reads the repository with the GitHub App when it is installed on it, otherwise with the connecting user's token
+func githubHeaders(repo Repo) (int, map[string]string, error) {
+	githubId, convErr := strconv.Atoi(repo.Id)
+	if convErr != nil {
+		return 0, nil, fmt.Errorf("[GIT] GitHub repository id %q is not a number", repo.Id)
+	}
+
+	accessToken, err := githubapp.RepositoryToken(githubId, repo.UserId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return githubId, map[string]string{"Authorization": "Bearer " + accessToken}, nil
+}
@@ -0,0 +1,141 @@
+package gitprovider
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type GitLab struct{}
+
+func (GitLab) ListRepositories(repo Repo) ([]Repository, error) {
+	if len(repo.Token) == 0 {
+		return nil, fmt.Errorf("[GIT] listing GitLab projects needs an access token")
+	}
+
+	api, headers, err := gitlabAPI(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{"membership": "true", "order_by": "last_activity_at", "per_page": "100"}
+
+	var response []struct {
+		Id                int    `json:"id"`
+		PathWithNamespace string `json:"path_with_namespace"`
+		AvatarURL         string `json:"avatar_url"`
+		WebURL            string `json:"web_url"`
+	}
+
+	if err := requestJSON(api+"/projects", headers, params, &response); err != nil {
+		return nil, err
+	}
+
+	repositories := []Repository{}
+	for _, project := range response {
+		repositories = append(repositories, Repository{
+			Id:        strconv.Itoa(project.Id),
+			FullName:  project.PathWithNamespace,
+			AvatarURL: project.AvatarURL,
+			URL:       project.WebURL,
+		})
+	}
+
+	return repositories, nil
+}
+
+func (GitLab) ResolveRef(repo Repo, ref string) (string, error) {
+	api, headers, err := gitlabAPI(repo)
+	if err != nil {
+		return "", err
+	}
+
+	projectURL := api + "/projects/" + url.PathEscape(repo.Id)
+
+	if len(ref) == 0 {
+		var project struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+
+		if err := requestJSON(projectURL, headers, nil, &project); err != nil {
+			return "", err
+		}
+
+		if len(project.DefaultBranch) == 0 {
+			return "", fmt.Errorf("[GIT] No commits have been made")
+		}
+
+		ref = project.DefaultBranch
+	}
+
+	var commit struct {
+		Id string `json:"id"`
+	}
+
+	if err := requestJSON(projectURL+"/repository/commits/"+url.PathEscape(ref), headers, nil, &commit); err != nil {
+		return "", err
+	}
+
+	return commit.Id, nil
+}
+
+func (GitLab) WebURL(repo Repo) (string, error) {
+	api, headers, err := gitlabAPI(repo)
+	if err != nil {
+		return "", err
+	}
+
+	var project struct {
+		WebURL string `json:"web_url"`
+	}
+
+	if err := requestJSON(api+"/projects/"+url.PathEscape(repo.Id), headers, nil, &project); err != nil {
+		return "", err
+	}
+
+	return project.WebURL, nil
+}
+
+// VerifyWebhook compares the X-Gitlab-Token header, GitLab sends the secret as it is rather than signing the body
+func (GitLab) VerifyWebhook(r *http.Request, body []byte, secret string) (*Push, error) {
+	token := r.Header.Get("X-Gitlab-Token")
+	if len(secret) == 0 || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return nil, ErrUnverified
+	}
+
+	if r.Header.Get("X-Gitlab-Event") != "Push Hook" {
+		return nil, nil
+	}
+
+	var event struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Project struct {
+			Id            int    `json:"id"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"project"`
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	return defaultBranchPush(strconv.Itoa(event.Project.Id), event.Ref, event.After, event.Project.DefaultBranch), nil
+}
+
+func gitlabAPI(repo Repo) (string, map[string]string, error) {
+	host, err := HostURL(repo.Host, "https://gitlab.com")
+	if err != nil {
+		return "", nil, err
+	}
+
+	headers := map[string]string{}
+	if len(repo.Token) > 0 {
+		headers["PRIVATE-TOKEN"] = repo.Token
+	}
+
+	return host + "/api/v4", headers, nil
+}
@@ -0,0 +1,182 @@
+package gitprovider
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"httpServer/src/outbound"
+	"httpServer/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is returned by providers that have no api for the operation
+var ErrUnsupported = errors.New("[GIT] the provider does not support this")
+
+// ErrUnverified is returned when a webhook delivery does not carry a valid signature
+var ErrUnverified = errors.New("[GIT] webhook delivery could not be verified")
+
+// hosts come from users, the client keeps them from reaching the internal network through a provider api
+var client = outbound.HTTPClient(30 * time.Second)
+
+// Repo is a project's repository and what is needed to read it
+type Repo struct {
+	Provider string
+	// the numeric id on GitHub, the numeric id or `group/project` path on GitLab,
+	// `owner/name` on Gitea and the https url for plain git
+	Id string
+	// base url of a self hosted GitLab or Gitea, empty for gitlab.com
+	Host string
+	// access token of a private GitLab or Gitea repository
+	Token string
+	// GitHub repositories fall back to the OAuth token of the user who connected them
+	UserId int
+}
+
+// Repository is a repository the user can connect a project to
+type Repository struct {
+	Id        string `json:"id"`
+	FullName  string `json:"full_name"`
+	AvatarURL string `json:"avatar_url"`
+	URL       string `json:"url"`
+}
+
+// Push is a verified push to a repository's default branch
+type Push struct {
+	RepoId string
+	Sha    string
+}
+
+type Provider interface {
+	// ListRepositories lists the repositories the credentials of repo can read, its Id is ignored
+	ListRepositories(repo Repo) ([]Repository, error)
+	// ResolveRef returns the sha of the commit a branch, tag or sha points to, an empty ref is the default branch
+	ResolveRef(repo Repo, ref string) (string, error)
+	// WebURL is the page of the repository on the provider
+	WebURL(repo Repo) (string, error)
+	// VerifyWebhook checks the delivery against the secret, a nil push means the delivery has nothing to build
+	VerifyWebhook(r *http.Request, body []byte, secret string) (*Push, error)
+}
+
+var providers = map[string]Provider{
+	"github": GitHub{},
+	"gitlab": GitLab{},
+	"gitea":  Gitea{},
+	"git":    Git{},
+}
+
+func For(name string) (Provider, error) {
+	provider, found := providers[name]
+	if !found {
+		return nil, fmt.Errorf("[GIT] unknown provider %q", name)
+	}
+
+	return provider, nil
+}
+
+// HostURL is the base url of a self hosted provider, only https urls of public addresses are accepted
+func HostURL(host string, fallback string) (string, error) {
+	if len(strings.TrimSpace(host)) == 0 {
+		if len(fallback) == 0 {
+			return "", fmt.Errorf("[GIT] the provider needs a host")
+		}
+		return fallback, nil
+	}
+
+	parsed, err := url.Parse(host)
+	if err != nil || parsed.Scheme != "https" || len(parsed.Host) == 0 || parsed.User != nil {
+		return "", fmt.Errorf("[GIT] host has to be an https url")
+	}
+
+	if err := outbound.CheckURL(host); err != nil {
+		return "", fmt.Errorf("[GIT] host has to be a public address")
+	}
+
+	return strings.TrimSuffix(host, "/"), nil
+}
+
+// Decrypt opens a repository token or webhook secret, they are encrypted with ENV_SECRET like environment values
+func Decrypt(encrypted string) (string, error) {
+	block, err := cipherBlock()
+	if err != nil {
+		return "", err
+	}
+
+	cipherText, err := hex.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	if len(cipherText) < aes.BlockSize {
+		return "", fmt.Errorf("[ENC] cipher text is too short")
+	}
+
+	iv := cipherText[:aes.BlockSize]
+	cipherText = cipherText[aes.BlockSize:]
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(cipherText, cipherText)
+
+	return string(cipherText), nil
+}
+
+func cipherBlock() (cipher.Block, error) {
+	key, keyExists := os.LookupEnv("ENV_SECRET")
+	if !keyExists {
+		return nil, fmt.Errorf("[ENC] env secret is not accessible")
+	}
+
+	return aes.NewCipher([]byte(key))
+}
+
+func requestJSON(requestURL string, headers map[string]string, params map[string]string, response any) error {
+	resp, err := utils.RequestWith(client, "GET", requestURL, &headers, &params, nil)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return readErr
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("[GIT] %s answered %d: %s", requestURL, resp.StatusCode, string(respBody))
+	}
+
+	return json.Unmarshal(respBody, response)
+}
+
+// validSignature compares a hex encoded HMAC-SHA256 of the body in constant time
+func validSignature(body []byte, secret string, signature string) bool {
+	if len(secret) == 0 || len(signature) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// defaultBranchPush turns a push delivery into a Push, pushes to other branches and branch deletions are ignored
+func defaultBranchPush(repoId string, ref string, after string, defaultBranch string) *Push {
+	if ref != "refs/heads/"+defaultBranch || len(strings.Trim(after, "0")) == 0 {
+		return nil
+	}
+
+	return &Push{RepoId: repoId, Sha: after}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"httpServer/config"
+	"httpServer/src/access"
+	"httpServer/utils"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProjectParam lets the request through when the user holds at least the required role on the project named by the url parameter
+func ProjectParam(param string, required access.Role) func(http.Handler) http.Handler {
+	return authorize(required, access.Project, func(r *http.Request) (any, error) {
+		return idParam(r, param), nil
+	})
+}
+
+// ProjectBody is ProjectParam for handlers that take the project as `project_id` in their json body,
+// the body is put back so the handler can still read it
+func ProjectBody(required access.Role) func(http.Handler) http.Handler {
+	return authorize(required, access.Project, func(r *http.Request) (any, error) {
+		body, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var requestBody struct {
+			ProjectId int `json:"project_id"`
+		}
+
+		if err := json.Unmarshal(body, &requestBody); err != nil {
+			return nil, err
+		}
+
+		return requestBody.ProjectId, nil
+	})
+}
+
+// BuildParam checks the role on the project the build named by the url parameter belongs to
+func BuildParam(param string, required access.Role) func(http.Handler) http.Handler {
+	return authorize(required, access.Project, func(r *http.Request) (any, error) {
+		var projectId int
+
+		query := `SELECT b.project_id FROM "deploy-io".builds b WHERE b.id = $1`
+		err := config.DataBase.QueryRow(query, idParam(r, param)).Scan(&projectId)
+		if err == sql.ErrNoRows {
+			// an unknown build is answered like a build of someone else's project
+			return 0, nil
+		}
+
+		return projectId, err
+	})
+}
+
+// OrgParam lets the request through when the user holds at least the required role in the organization named by the url parameter
+func OrgParam(param string, required access.Role) func(http.Handler) http.Handler {
+	return authorize(required, access.Org, func(r *http.Request) (any, error) {
+		return idParam(r, param), nil
+	})
+}
+
+// idParam reads a numeric id from the url, anything else becomes 0 which no row has,
+// so a malformed id is answered like a missing one instead of failing in the query
+func idParam(r *http.Request, param string) int {
+	id, err := strconv.Atoi(chi.URLParam(r, param))
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+func authorize(required access.Role, check func(http.ResponseWriter, any, int, access.Role) bool, resource func(*http.Request) (any, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId := utils.GetUserIdFromContext(w, r)
+			if userId == nil {
+				return
+			}
+
+			if !scopeAllows(r, required) {
+				errMsg := "[AUTH] Token scope does not allow the " + string(required) + " role"
+				utils.HandleError(utils.ErrForbidden, nil, w, &errMsg)
+				return
+			}
+
+			id, resourceErr := resource(r)
+			if resourceErr != nil {
+				utils.HandleError(utils.ErrInvalid, resourceErr, w, nil)
+				return
+			}
+
+			if !check(w, id, *userId, required) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package middleware_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"httpServer/config"
+	build "httpServer/src/routes/Build"
+	deployment "httpServer/src/routes/Deployment"
+	organization "httpServer/src/routes/Organization"
+	project "httpServer/src/routes/Project"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const jwtSecret = "access-test-secret"
+
+// the users and projects every test runs against:
+// user 1 owns personal project 1 and organization 10, which owns project 2. User 2 is a viewer in
+// organization 10 and owns nothing
+const (
+	owner    = 1
+	stranger = 2
+
+	personalProject = 1
+	orgProject      = 2
+	personalBuild   = 100
+	orgBuild        = 200
+	org             = 10
+)
+
+type testProject struct {
+	userId int64
+	orgId  int64
+}
+
+var (
+	projects    = map[int64]testProject{personalProject: {userId: owner}, orgProject: {orgId: org}}
+	builds      = map[int64]int64{personalBuild: personalProject, orgBuild: orgProject}
+	memberships = map[[2]int64]string{{org, owner}: "owner", {org, stranger}: "viewer"}
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", jwtSecret)
+
+	sql.Register("accesstest", &fakeDriver{})
+
+	db, err := sql.Open("accesstest", "")
+	if err != nil {
+		panic(err)
+	}
+	config.DataBase = db
+
+	os.Exit(m.Run())
+}
+
+func TestOtherUsersProjectIsRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		router func() chi.Router
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"build of a personal project", build.BuildRouter, "GET", fmt.Sprintf("/%d", personalBuild), "", http.StatusNotFound},
+		{"unknown build", build.BuildRouter, "GET", "/999", "", http.StatusNotFound},
+		{"builds of a personal project", build.BuildRouter, "GET", fmt.Sprintf("/all/%d", personalProject), "", http.StatusNotFound},
+		{"new build of an org project as viewer", build.BuildRouter, "POST", "/new", fmt.Sprintf(`{"project_id": %d}`, orgProject), http.StatusForbidden},
+		{"deployment of a personal project", deployment.DeploymentRouter, "GET", fmt.Sprintf("/%d", personalProject), "", http.StatusNotFound},
+		{"deployment with a malformed id", deployment.DeploymentRouter, "GET", "/abc", "", http.StatusNotFound},
+		{"deactivate a personal project", deployment.DeploymentRouter, "DELETE", "/deactivate", fmt.Sprintf(`{"project_id": %d}`, personalProject), http.StatusNotFound},
+		{"deactivate an org project as viewer", deployment.DeploymentRouter, "DELETE", "/deactivate", fmt.Sprintf(`{"project_id": %d}`, orgProject), http.StatusForbidden},
+		{"environments of a personal project", project.ProjectRouter, "POST", "/environments", fmt.Sprintf(`{"project_id": %d, "environments": [{"key": "A", "value": "b"}]}`, personalProject), http.StatusNotFound},
+		{"environments of an org project as viewer", project.ProjectRouter, "POST", "/environments", fmt.Sprintf(`{"project_id": %d, "environments": [{"key": "A", "value": "b"}]}`, orgProject), http.StatusForbidden},
+		{"settings of a personal project", project.ProjectRouter, "PATCH", fmt.Sprintf("/%d", personalProject), `{}`, http.StatusNotFound},
+		{"delete an org as viewer", organization.OrganizationRouter, "DELETE", fmt.Sprintf("/%d", org), "", http.StatusForbidden},
+		{"org the user is not in", organization.OrganizationRouter, "GET", "/11", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, reached := serve(t, test.router(), stranger, test.method, test.path, test.body)
+
+			if status != test.status {
+				t.Errorf("status = %d, want %d", status, test.status)
+			}
+			if reached {
+				t.Errorf("the handler ran for a user without access")
+			}
+		})
+	}
+}
+
+func TestOwnerReachesHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		router func() chi.Router
+		method string
+		path   string
+		body   string
+	}{
+		{"build", build.BuildRouter, "GET", fmt.Sprintf("/%d", personalBuild), ""},
+		{"build of an org project", build.BuildRouter, "GET", fmt.Sprintf("/%d", orgBuild), ""},
+		{"deployment", deployment.DeploymentRouter, "GET", fmt.Sprintf("/%d", personalProject), ""},
+		{"deactivate", deployment.DeploymentRouter, "DELETE", "/deactivate", fmt.Sprintf(`{"project_id": %d}`, orgProject)},
+		{"environments", project.ProjectRouter, "POST", "/environments", fmt.Sprintf(`{"project_id": %d, "environments": [{"key": "A", "value": "b"}]}`, personalProject)},
+		{"org", organization.OrganizationRouter, "GET", fmt.Sprintf("/%d", org), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, reached := serve(t, test.router(), owner, test.method, test.path, test.body)
+
+			if !reached {
+				t.Errorf("the handler did not run for the owner, status %d", status)
+			}
+		})
+	}
+}
+
+func TestViewerCanRead(t *testing.T) {
+	_, reached := serve(t, build.BuildRouter(), stranger, "GET", fmt.Sprintf("/%d", orgBuild), "")
+	if !reached {
+		t.Errorf("a viewer of the organization could not read its build")
+	}
+}
+
+// serve sends the request as the user and reports the status and whether the handler got to query the database
+func serve(t *testing.T, router chi.Router, userId int, method string, path string, body string) (int, bool) {
+	t.Helper()
+
+	handlerQueries.reset()
+
+	claims := map[string]interface{}{"uId": userId, "sid": 1}
+	jwtauth.SetExpiryIn(claims, time.Minute)
+	_, token, err := jwtauth.New("HS256", []byte(jwtSecret), nil).Encode(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, r)
+
+	return w.Code, handlerQueries.count() > 0
+}
+
+// handlerQueries counts the queries the fake database does not answer, only handlers run those
+var handlerQueries queryLog
+
+type queryLog struct {
+	mutex   sync.Mutex
+	queries []string
+}
+
+func (l *queryLog) add(query string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.queries = append(l.queries, query)
+}
+
+func (l *queryLog) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.queries = nil
+}
+
+func (l *queryLog) count() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.queries)
+}
+
+var errHandlerQuery = errors.New("query is not answered by the test database")
+
+// fakeDriver answers the queries the auth and access middleware run, every other query is logged and fails
+type fakeDriver struct{}
+
+func (*fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (*fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if answer(query) == nil {
+		handlerQueries.add(query)
+		return nil, errHandlerQuery
+	}
+
+	return &fakeStmt{query: query}, nil
+}
+
+func (*fakeConn) Close() error { return nil }
+
+func (*fakeConn) Begin() (driver.Tx, error) {
+	handlerQueries.add("BEGIN")
+	return nil, errHandlerQuery
+}
+
+type fakeStmt struct{ query string }
+
+func (*fakeStmt) Close() error  { return nil }
+func (*fakeStmt) NumInput() int { return -1 }
+
+func (*fakeStmt) Exec([]driver.Value) (driver.Result, error) { return nil, errHandlerQuery }
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return answer(s.query)(args), nil
+}
+
+// answer returns how the query is answered, nil when the fake database does not know it
+func answer(query string) func([]driver.Value) driver.Rows {
+	switch {
+	case strings.Contains(query, `FROM "deploy-io".sessions s`):
+		return func([]driver.Value) driver.Rows { return rows(true) }
+	case strings.Contains(query, "THEN 'owner'"):
+		return projectRole
+	case strings.Contains(query, `SELECT b.project_id FROM "deploy-io".builds b`):
+		return func(args []driver.Value) driver.Rows {
+			if projectId, found := builds[args[0].(int64)]; found {
+				return rows(projectId)
+			}
+			return rows()
+		}
+	case strings.Contains(query, `SELECT m.role FROM "deploy-io".org_memberships m`):
+		return func(args []driver.Value) driver.Rows {
+			if role, found := memberships[[2]int64{args[0].(int64), args[1].(int64)}]; found {
+				return rows(role)
+			}
+			return rows()
+		}
+	}
+
+	return nil
+}
+
+func projectRole(args []driver.Value) driver.Rows {
+	p, found := projects[args[0].(int64)]
+	if !found {
+		return rows()
+	}
+
+	userId := args[1].(int64)
+	if p.orgId == 0 {
+		if p.userId == userId {
+			return rows("owner")
+		}
+		return rows(nil)
+	}
+
+	if role, member := memberships[[2]int64{p.orgId, userId}]; member {
+		return rows(role)
+	}
+	return rows(nil)
+}
+
+// rows is a result of a single row holding the values, or no row without them
+func rows(values ...driver.Value) driver.Rows {
+	if len(values) == 0 {
+		return &fakeRows{}
+	}
+
+	return &fakeRows{columns: len(values), values: [][]driver.Value{values}}
+}
+
+type fakeRows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return make([]string, r.columns) }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+
+	return nil
+}
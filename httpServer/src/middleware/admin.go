@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	auth "httpServer/src/routes/Auth"
+	"httpServer/utils"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// ActiveUser turns away tokens of revoked sessions and of accounts an admin disabled, both are still signed correctly.
+// Sign in tokens from before sessions existed carry no session and have to sign in again
+func ActiveUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId := utils.GetUserIdFromContext(w, r)
+		if userId == nil {
+			return
+		}
+
+		_, claims, _ := jwtauth.FromContext(r.Context())
+
+		// personal access tokens are checked against the database when they are verified
+		if _, scoped := tokenScope(r); scoped {
+			if !auth.IsUserActive(*userId) {
+				errMsg := "[AUTH] Account was disabled"
+				utils.HandleError(utils.ErrForbidden, nil, w, &errMsg)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sessionId, hasSession := auth.SessionId(claims)
+		if !hasSession || !auth.IsSessionActive(sessionId, *userId) {
+			errMsg := "[AUTH] Session ended, sign in again"
+			utils.HandleError(utils.ErrUnAuthorized, nil, w, &errMsg)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin lets only users holding the platform wide admin role through
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId := utils.GetUserIdFromContext(w, r)
+		if userId == nil {
+			return
+		}
+
+		isAdmin, err := auth.IsAdmin(*userId)
+		if err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		if !isAdmin {
+			utils.HandleError(utils.ErrForbidden, nil, w, nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
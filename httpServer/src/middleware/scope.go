@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+	"httpServer/src/access"
+	auth "httpServer/src/routes/Auth"
+	"httpServer/utils"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// the widest project role a personal access token can act with, sign in tokens are not limited
+var scopeRoles = map[string]access.Role{
+	auth.ScopeRead:   access.RoleViewer,
+	auth.ScopeDeploy: access.RoleDeveloper,
+	auth.ScopeAdmin:  access.RoleOwner,
+}
+
+// Verifier is jwtauth.Verifier that also accepts personal access tokens in the Authorization header,
+// a valid one is put in the context as a JWT so jwtauth.Authenticator and the handlers treat both alike
+func Verifier(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler {
+	verify := jwtauth.Verifier(ja)
+
+	return func(next http.Handler) http.Handler {
+		verified := verify(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := jwtauth.TokenFromHeader(r)
+			if !strings.HasPrefix(tokenString, auth.AccessTokenPrefix) {
+				verified.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := auth.AccessTokenClaims(tokenString)
+			if err != nil {
+				next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), nil, err)))
+				return
+			}
+
+			token, _, err := ja.Encode(claims)
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, err)))
+		})
+	}
+}
+
+// RequireScope turns away personal access tokens that were not given the scope, sign in tokens always pass
+func RequireScope(required string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if scope, scoped := tokenScope(r); scoped && !auth.ScopeIncludes(scope, required) {
+				errMsg := fmt.Sprintf("[AUTH] Token needs the %s scope", required)
+				utils.HandleError(utils.ErrForbidden, nil, w, &errMsg)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// scopeAllows tells whether the request's token may act with the role, it does not check that the user holds it
+func scopeAllows(r *http.Request, required access.Role) bool {
+	scope, scoped := tokenScope(r)
+	if !scoped {
+		return true
+	}
+
+	return scopeRoles[scope].Includes(required)
+}
+
+func tokenScope(r *http.Request) (string, bool) {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+
+	scope, scoped := claims["scope"].(string)
+
+	return scope, scoped
+}
@@ -1,11 +1,16 @@
 package src
 
 import (
+	admin "httpServer/src/routes/Admin"
 	auth "httpServer/src/routes/Auth"
 	build "httpServer/src/routes/Build"
 	deployment "httpServer/src/routes/Deployment"
+	git "httpServer/src/routes/Git"
 	github "httpServer/src/routes/Github"
+	organization "httpServer/src/routes/Organization"
 	project "httpServer/src/routes/Project"
+	session "httpServer/src/routes/Session"
+	token "httpServer/src/routes/Token"
 	user "httpServer/src/routes/User"
 	"net/http"
 
@@ -25,7 +30,7 @@ func Service() http.Handler {
 
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 		AllowCredentials: true,
 	}))
@@ -33,9 +38,14 @@ func Service() http.Handler {
 	router.Mount("/api/v1/auth", auth.AuthRouter())
 	router.Mount("/api/v1/dashboard", user.UserRouter())
 	router.Mount("/api/v1/github", github.GithubRouter())
+	router.Mount("/api/v1/git", git.GitRouter())
 	router.Mount("/api/v1/project", project.ProjectRouter())
+	router.Mount("/api/v1/org", organization.OrganizationRouter())
 	router.Mount("/api/v1/build", build.BuildRouter())
 	router.Mount("/api/v1/deployment", deployment.DeploymentRouter())
+	router.Mount("/api/v1/admin", admin.AdminRouter())
+	router.Mount("/api/v1/tokens", token.TokenRouter())
+	router.Mount("/api/v1/sessions", session.SessionRouter())
 
 	router.Handle("/metrics", promhttp.Handler())
 
@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"httpServer/src/middleware"
+	auth "httpServer/src/routes/Auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// AdminRouter works across every tenant, it is only open to users whose role is admin
+func AdminRouter() chi.Router {
+	r := chi.NewRouter()
+
+	a := AdminHandler{}
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
+
+		r.Use(middleware.RequireAdmin)
+		r.Use(middleware.RequireScope(auth.ScopeAdmin))
+
+		r.Get("/users", a.ListUsers)
+		r.Put("/users/{id}/status", a.UpdateUserStatus)
+		r.Get("/projects", a.ListProjects)
+		r.Put("/projects/{id}/suspension", a.UpdateSuspension)
+		r.Get("/builds", a.ListBuilds)
+		r.Post("/builds/{id}/requeue", a.RequeueBuild)
+		r.Get("/stats", a.Stats)
+	})
+
+	return r
+}
@@ -0,0 +1,415 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"httpServer/config"
+	auth "httpServer/src/routes/Auth"
+	build "httpServer/src/routes/Build"
+	"httpServer/utils"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/minio/minio-go/v7"
+)
+
+func (a AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, pageNumber := page(r)
+
+	query := `
+		SELECT u.id, u.email, u.name, u.role, COALESCE(u.status, true),
+			(SELECT COUNT(*) FROM "deploy-io".projects p WHERE p.user_id = u.id)
+		FROM "deploy-io".users u
+		WHERE $1 = '' OR u.email ILIKE '%' || $1 || '%' OR u.name ILIKE '%' || $1 || '%'
+		ORDER BY u.id LIMIT $2 OFFSET $3;
+	`
+	rows, queryErr := config.DataBase.Query(query, r.URL.Query().Get("q"), limit, (pageNumber-1)*limit)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	users := []User{}
+
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.Id, &user.Email, &user.Name, &user.Role, &user.Active, &user.Projects); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		users = append(users, user)
+	}
+
+	responseBody := map[string]any{
+		"users":       users,
+		"currentPage": pageNumber,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// UpdateUserStatus disables or enables an account, a disabled user can neither sign in nor use any token issued before
+func (a AdminHandler) UpdateUserStatus(w http.ResponseWriter, r *http.Request) {
+	adminId := utils.GetUserIdFromContext(w, r)
+	if adminId == nil {
+		return
+	}
+
+	targetId, parseErr := strconv.Atoi(chi.URLParam(r, "id"))
+	if parseErr != nil {
+		utils.HandleError(utils.ErrInvalid, parseErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateUserStatusBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.Active == nil {
+		utils.HandleError(utils.ErrInvalid, err, w, nil)
+		return
+	}
+
+	if targetId == *adminId && !*requestBody.Active {
+		errMsg := "You can not disable your own account"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	query := `UPDATE "deploy-io".users SET status = $1 WHERE id = $2`
+	result, updateErr := config.DataBase.Exec(query, *requestBody.Active, targetId)
+	if updateErr != nil {
+		utils.HandleError(utils.ErrInternal, updateErr, w, nil)
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, err, w, nil)
+		return
+	}
+
+	// the user's sessions end with the account so enabling it again does not bring them back
+	if !*requestBody.Active {
+		if err := auth.RevokeSessions(targetId); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+	}
+
+	fmt.Printf("[ADMIN] user %d set user %d active to %t\n", *adminId, targetId, *requestBody.Active)
+
+	responseBody := map[string]any{
+		"id":     targetId,
+		"active": *requestBody.Active,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (a AdminHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	limit, pageNumber := page(r)
+
+	query := `
+		SELECT p.id, p.name, p.user_id, p.org_id,
+			EXISTS(SELECT 1 FROM "deploy-io".deployments d WHERE d.project_id = p.id AND d.status = TRUE),
+			p.suspended, p.suspended_at, p.created_at
+		FROM "deploy-io".projects p
+		WHERE ($1 = '' OR p.name ILIKE '%' || $1 || '%') AND ($2 = '' OR p.suspended = ($2 = 'true'))
+		ORDER BY p.id LIMIT $3 OFFSET $4;
+	`
+	rows, queryErr := config.DataBase.Query(query, r.URL.Query().Get("q"), r.URL.Query().Get("suspended"), limit, (pageNumber-1)*limit)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	projects := []Project{}
+
+	for rows.Next() {
+		var project Project
+		var orgId sql.NullInt64
+		var suspendedAt sql.NullTime
+
+		if err := rows.Scan(&project.Id, &project.Name, &project.UserId, &orgId, &project.Active, &project.Suspended, &suspendedAt, &project.CreatedAt); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		if orgId.Valid {
+			id := int(orgId.Int64)
+			project.OrgId = &id
+		}
+		if suspendedAt.Valid {
+			project.SuspendedAt = &suspendedAt.Time
+		}
+
+		projects = append(projects, project)
+	}
+
+	responseBody := map[string]any{
+		"projects":    projects,
+		"currentPage": pageNumber,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// UpdateSuspension takes a project offline regardless of its deployments, the files are kept
+// so lifting the suspension brings the site back as it was
+func (a AdminHandler) UpdateSuspension(w http.ResponseWriter, r *http.Request) {
+	adminId := utils.GetUserIdFromContext(w, r)
+	if adminId == nil {
+		return
+	}
+
+	projectId := chi.URLParam(r, "id")
+
+	var requestBody UpdateSuspensionBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.Suspended == nil {
+		utils.HandleError(utils.ErrInvalid, err, w, nil)
+		return
+	}
+
+	query := `
+		UPDATE "deploy-io".projects
+		SET suspended = $1, suspended_at = CASE WHEN $1 THEN CURRENT_TIMESTAMP ELSE NULL END
+		WHERE id = $2
+	`
+	result, updateErr := config.DataBase.Exec(query, *requestBody.Suspended, projectId)
+	if updateErr != nil {
+		utils.HandleError(utils.ErrInternal, updateErr, w, nil)
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, err, w, nil)
+		return
+	}
+
+	fmt.Printf("[ADMIN] user %d set project %s suspended to %t\n", *adminId, projectId, *requestBody.Suspended)
+
+	responseBody := map[string]any{
+		"id":        projectId,
+		"suspended": *requestBody.Suspended,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (a AdminHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
+	limit, pageNumber := page(r)
+
+	query := `
+		SELECT b.id, b.project_id, p.name, b.status, b.triggered_by, b.commit_hash, b.created_at
+		FROM "deploy-io".builds b
+		JOIN "deploy-io".projects p ON p.id = b.project_id
+		WHERE $1 = '' OR b.status::text = $1
+		ORDER BY b.id DESC LIMIT $2 OFFSET $3;
+	`
+	rows, queryErr := config.DataBase.Query(query, r.URL.Query().Get("status"), limit, (pageNumber-1)*limit)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	builds := []Build{}
+
+	for rows.Next() {
+		var build Build
+		if err := rows.Scan(&build.Id, &build.ProjectId, &build.ProjectName, &build.Status, &build.TriggeredBy, &build.CommitHash, &build.CreatedAt); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		builds = append(builds, build)
+	}
+
+	responseBody := map[string]any{
+		"builds":      builds,
+		"currentPage": pageNumber,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (a AdminHandler) RequeueBuild(w http.ResponseWriter, r *http.Request) {
+	buildId, parseErr := strconv.Atoi(chi.URLParam(r, "id"))
+	if parseErr != nil {
+		utils.HandleError(utils.ErrInvalid, parseErr, w, nil)
+		return
+	}
+
+	requeueErr := build.Requeue(buildId)
+	if requeueErr == sql.ErrNoRows {
+		errMsg := "Only failed builds and builds waiting in the queue can be requeued"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+	if requeueErr != nil {
+		utils.HandleError(utils.ErrInternal, requeueErr, w, nil)
+		return
+	}
+
+	response, constructorErr := json.Marshal(map[string]int{"build_id": buildId})
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Stats reports the build queue, the object storage and row counts of the whole platform.
+// Storage is summed by listing the bucket, it gets slower as the bucket grows
+func (a AdminHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	queue, queueErr := config.RabbitChannel.QueueDeclarePassive(config.RabbitQueue.Name, true, false, false, false, nil)
+	if queueErr != nil {
+		utils.HandleError(utils.ErrInternal, queueErr, w, nil)
+		return
+	}
+
+	storage, storageErr := storageStats(r.Context())
+	if storageErr != nil {
+		utils.HandleError(utils.ErrInternal, storageErr, w, nil)
+		return
+	}
+
+	var users, disabledUsers, projects, suspendedProjects, activeDeployments int
+
+	countQuery := `
+		SELECT
+			(SELECT COUNT(*) FROM "deploy-io".users),
+			(SELECT COUNT(*) FROM "deploy-io".users u WHERE u.status = FALSE),
+			(SELECT COUNT(*) FROM "deploy-io".projects),
+			(SELECT COUNT(*) FROM "deploy-io".projects p WHERE p.suspended = TRUE),
+			(SELECT COUNT(DISTINCT d.project_id) FROM "deploy-io".deployments d WHERE d.status = TRUE);
+	`
+	countErr := config.DataBase.QueryRow(countQuery).Scan(&users, &disabledUsers, &projects, &suspendedProjects, &activeDeployments)
+	if countErr != nil {
+		utils.HandleError(utils.ErrInternal, countErr, w, nil)
+		return
+	}
+
+	builds, buildsErr := buildCounts()
+	if buildsErr != nil {
+		utils.HandleError(utils.ErrInternal, buildsErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]any{
+		"queue":              QueueStats{Name: queue.Name, Messages: queue.Messages, Consumers: queue.Consumers},
+		"storage":            storage,
+		"users":              users,
+		"disabled_users":     disabledUsers,
+		"projects":           projects,
+		"suspended_projects": suspendedProjects,
+		"active_deployments": activeDeployments,
+		"builds":             builds,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// buildCounts returns the number of builds in each status
+func buildCounts() (map[string]int, error) {
+	query := `SELECT b.status, COUNT(*) FROM "deploy-io".builds b GROUP BY b.status`
+	rows, queryErr := config.DataBase.Query(query)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	defer rows.Close()
+
+	counts := map[string]int{}
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func storageStats(ctx context.Context) (StorageStats, error) {
+	var stats StorageStats
+
+	bucketName, bucketExists := os.LookupEnv("MIO_BUCKET")
+	if !bucketExists {
+		return stats, fmt.Errorf("[ADMIN] bucket name was not set in env variable")
+	}
+
+	for object := range config.Minio.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return stats, object.Err
+		}
+
+		stats.Objects++
+		stats.Bytes += object.Size
+	}
+
+	return stats, nil
+}
+
+// page reads the `l` and `p` query parameters the list endpoints share
+func page(r *http.Request) (int, int) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("l"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	pageNumber, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+
+	return limit, pageNumber
+}
@@ -0,0 +1,54 @@
+package admin
+
+import "time"
+
+type AdminHandler struct{}
+
+type User struct {
+	Id       int    `json:"id"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Active   bool   `json:"active"`
+	Projects int    `json:"projects"`
+}
+
+type Project struct {
+	Id          int        `json:"id"`
+	Name        string     `json:"name"`
+	UserId      int        `json:"user_id"`
+	OrgId       *int       `json:"org_id"`
+	Active      bool       `json:"active"`
+	Suspended   bool       `json:"suspended"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type Build struct {
+	Id          int       `json:"build_id"`
+	ProjectId   int       `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Status      string    `json:"build_status"`
+	TriggeredBy string    `json:"triggered_by"`
+	CommitHash  string    `json:"commit_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type UpdateUserStatusBody struct {
+	Active *bool `json:"active"`
+}
+
+type UpdateSuspensionBody struct {
+	Suspended *bool `json:"suspended"`
+}
+
+type QueueStats struct {
+	Name      string `json:"name"`
+	Messages  int    `json:"messages"`
+	Consumers int    `json:"consumers"`
+}
+
+type StorageStats struct {
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
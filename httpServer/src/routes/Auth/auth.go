@@ -7,7 +7,10 @@ func AuthRouter() chi.Router {
 
 	u := AuthHandler{}
 
+	r.Get("/authorize", u.Authorize)
 	r.Post("/signin", u.SignIn)
+	r.Post("/refresh", u.Refresh)
+	r.Post("/signout", u.SignOut)
 
 	return r
 }
@@ -1,21 +1,72 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"httpServer/config"
 	"httpServer/utils"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/lib/pq"
 )
 
+// Authorize starts a sign in, it returns the GitHub url to send the user to along with a signed state that expires
+// in ten minutes. The PKCE verifier is kept in an http only cookie that SignIn needs to find next to the state
+func (u AuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	verifierBytes := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, verifierBytes); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	verifier := base64.RawURLEncoding.EncodeToString(verifierBytes)
+	challenge := pkceChallenge(verifier)
+
+	state, stateErr := signState(verifier, time.Now().Add(oauthStateTTL))
+	if stateErr != nil {
+		utils.HandleError(utils.ErrInternal, stateErr, w, nil)
+		return
+	}
+
+	cId, _ := GetClientIdnSecret()
+
+	params := url.Values{}
+	params.Set("client_id", cId)
+	params.Set("state", state)
+	params.Set("code_challenge", challenge)
+	params.Set("code_challenge_method", "S256")
+
+	http.SetCookie(w, oauthCookie(verifier, int(oauthStateTTL.Seconds())))
+
+	responseBody, constructorErr := json.Marshal(map[string]string{
+		"url":                   getOauthURL() + "/login/oauth/authorize?" + params.Encode(),
+		"state":                 state,
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+	})
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
 func (u AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 	var user UserSignInPayload
 
@@ -31,6 +82,17 @@ func (u AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the verifier never leaves this browser's cookie, a code stolen or planted by someone else can not be exchanged with it
+	verifierCookie, cookieErr := r.Cookie(oauthCookieName)
+	if cookieErr != nil || !verifyState(user.State, verifierCookie.Value) {
+		errMsg := "[AUTH] Sign in state was invalid or expired"
+		utils.HandleError(utils.ErrUnAuthorized, cookieErr, w, &errMsg)
+		return
+	}
+
+	http.SetCookie(w, oauthCookie("", -1))
+	user.CodeVerifier = verifierCookie.Value
+
 	cId, cSecret := GetClientIdnSecret()
 
 	response, err := GetOauthResponse(cId, cSecret, user)
@@ -73,13 +135,122 @@ func (u AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 		}
 
 	} else {
+		if !IsUserActive(int(*userId)) {
+			errMsg := "[AUTH] Account was disabled"
+			utils.HandleError(utils.ErrForbidden, nil, w, &errMsg)
+			return
+		}
+
 		UpdateUserTokens(response, *userId)
 	}
 
-	jwtToken := generateJWT(*userId)
+	refreshToken, refreshErr := newRefreshToken()
+	if refreshErr != nil {
+		utils.HandleError(utils.ErrInternal, refreshErr, w, nil)
+		return
+	}
 
-	body := map[string]string{
-		"token": jwtToken,
+	var sessionId int
+
+	sessionQuery := `
+		INSERT INTO "deploy-io".sessions (user_id, refresh_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + make_interval(days => $5)) RETURNING id;
+	`
+	sessionErr := config.DataBase.QueryRow(sessionQuery, *userId, HashAccessToken(refreshToken), r.UserAgent(), r.RemoteAddr, getSessionDays()).Scan(&sessionId)
+	if sessionErr != nil {
+		utils.HandleError(utils.ErrInternal, sessionErr, w, nil)
+		return
+	}
+
+	writeTokens(w, *userId, sessionId, refreshToken)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh token, the one sent can not be used again.
+// Presenting a refresh token that was already traded means it leaked, so the session it belongs to is revoked
+func (u AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var requestBody RefreshSessionBody
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(strings.TrimSpace(requestBody.RefreshToken)) == 0 {
+		utils.HandleError(utils.ErrInvalid, err, w, nil)
+		return
+	}
+
+	refreshToken, refreshErr := newRefreshToken()
+	if refreshErr != nil {
+		utils.HandleError(utils.ErrInternal, refreshErr, w, nil)
+		return
+	}
+
+	var sessionId int
+	var userId int64
+
+	query := `
+		UPDATE "deploy-io".sessions s
+		SET previous_refresh_hash = s.refresh_hash, refresh_hash = $2, last_used_at = CURRENT_TIMESTAMP
+		FROM "deploy-io".users u
+		WHERE u.id = s.user_id AND s.refresh_hash = $1 AND s.revoked_at IS NULL
+		AND s.expires_at > CURRENT_TIMESTAMP AND COALESCE(u.status, true)
+		RETURNING s.id, s.user_id;
+	`
+	queryErr := config.DataBase.QueryRow(query, HashAccessToken(requestBody.RefreshToken), HashAccessToken(refreshToken)).Scan(&sessionId, &userId)
+	if queryErr == sql.ErrNoRows {
+		revokeQuery := `UPDATE "deploy-io".sessions SET revoked_at = CURRENT_TIMESTAMP WHERE previous_refresh_hash = $1 AND revoked_at IS NULL`
+		if result, revokeErr := config.DataBase.Exec(revokeQuery, HashAccessToken(requestBody.RefreshToken)); revokeErr == nil {
+			if revoked, _ := result.RowsAffected(); revoked > 0 {
+				fmt.Println("[AUTH] A used refresh token was presented again, its session was revoked")
+			}
+		}
+
+		utils.HandleError(utils.ErrUnAuthorized, nil, w, nil)
+		return
+	}
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	writeTokens(w, userId, sessionId, refreshToken)
+}
+
+// SignOut revokes the session the refresh token belongs to, the access token still in hand stops working with it
+func (u AuthHandler) SignOut(w http.ResponseWriter, r *http.Request) {
+	var requestBody RefreshSessionBody
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || len(strings.TrimSpace(requestBody.RefreshToken)) == 0 {
+		utils.HandleError(utils.ErrInvalid, err, w, nil)
+		return
+	}
+
+	query := `UPDATE "deploy-io".sessions SET revoked_at = CURRENT_TIMESTAMP WHERE refresh_hash = $1 AND revoked_at IS NULL`
+	result, queryErr := config.DataBase.Exec(query, HashAccessToken(requestBody.RefreshToken))
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.HandleError(utils.ErrUnAuthorized, err, w, nil)
+		return
+	}
+
+	responseBody, constructorErr := json.Marshal(map[string]string{"message": "Done"})
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+func writeTokens(w http.ResponseWriter, userId int64, sessionId int, refreshToken string) {
+	ttl := getAccessTokenTTL()
+
+	body := map[string]any{
+		"token":         generateJWT(userId, sessionId, ttl),
+		"refresh_token": refreshToken,
+		"expires_in":    int(ttl.Seconds()),
 	}
 
 	responseBody, err := json.Marshal(body)
@@ -91,6 +262,15 @@ func (u AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(responseBody))
 }
 
+func newRefreshToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, tokenBytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(tokenBytes), nil
+}
+
 func GetOauthResponse(cId string, cSecret string, user UserSignInPayload) (GH_UAT_API_Response, error) {
 
 	var GHAPIResponse GH_UAT_API_Response
@@ -105,12 +285,15 @@ func GetOauthResponse(cId string, cSecret string, user UserSignInPayload) (GH_UA
 
 	if len(strings.TrimSpace(user.Code)) > 0 {
 		params["code"] = user.Code
+		if len(user.CodeVerifier) > 0 {
+			params["code_verifier"] = user.CodeVerifier
+		}
 	} else if len(strings.TrimSpace(user.RefreshToken)) > 0 {
 		params["refresh_token"] = user.RefreshToken
 		params["grant_type"] = "refresh_token"
 	}
 
-	resp, err := utils.Request("POST", "https://github.com/login/oauth/access_token", nil, &params, nil)
+	resp, err := utils.Request("POST", getOauthURL()+"/login/oauth/access_token", nil, &params, nil)
 	if err != nil {
 		fmt.Println("[AUTH] Error while calling GH's UAT API")
 		return GHAPIResponse, err
@@ -148,7 +331,41 @@ func GetOauthResponse(cId string, cSecret string, user UserSignInPayload) (GH_UA
 	return GHAPIResponse, err
 }
 
+// ErrGithubTokenExpired is returned when the user's GitHub refresh token has expired and they need to sign in again
+var ErrGithubTokenExpired = errors.New("[AUTH] GitHub sign in has expired")
+
+// GetAccessToken returns the user's GitHub OAuth token, refreshing it first when it has expired. Only the code
+// that calls GitHub with the user's token goes through here, so requests that never reach GitHub, or reach it
+// as the GitHub App, do not depend on the user's refresh token
 func GetAccessToken(userId int) (*string, error) {
+	isAccessValid, isRefreshValid := AreTokensValid(userId)
+
+	if !isRefreshValid {
+		return nil, ErrGithubTokenExpired
+	}
+
+	if !isAccessValid {
+		var TokenPayload UserSignInPayload
+
+		query := `SELECT refresh FROM "deploy-io".users WHERE id = $1`
+		err := config.DataBase.QueryRow(query, userId).Scan(&TokenPayload.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+
+		cId, cSecret := GetClientIdnSecret()
+
+		response, err := GetOauthResponse(cId, cSecret, TokenPayload)
+		if err != nil {
+			println("[AUTH] Refresh failed: ", err.Error())
+			return nil, ErrGithubTokenExpired
+		}
+
+		UpdateUserTokens(response, int64(userId))
+
+		return &response.AccessToken, nil
+	}
+
 	query := `SELECT u.access FROM "deploy-io".users u WHERE u.id = $1`
 
 	var accessToken string
@@ -187,14 +404,250 @@ func AreTokensValid(userId int) (bool, bool) {
 	return isAccessValid, isRefreshValid
 }
 
-func generateJWT(userId int64) string {
+// IsUserActive is false for accounts an admin disabled, and for ones that could not be looked up
+func IsUserActive(userId int) bool {
+	var status sql.NullBool
+
+	query := `SELECT u.status FROM "deploy-io".users u WHERE u.id = $1`
+	err := config.DataBase.QueryRow(query, userId).Scan(&status)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			println("[AUTH] ", err.Error())
+		}
+		return false
+	}
+
+	// rows from before the column had a default are treated as active
+	return !status.Valid || status.Bool
+}
+
+// IsAdmin tells whether the user holds the platform wide admin role
+func IsAdmin(userId int) (bool, error) {
+	var role string
+
+	query := `SELECT u.role FROM "deploy-io".users u WHERE u.id = $1`
+	err := config.DataBase.QueryRow(query, userId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return role == "admin", nil
+}
+
+// ValidScope tells whether the scope is one a personal access token can be given
+func ValidScope(scope string) bool {
+	_, found := scopeRanks[scope]
+	return found
+}
+
+// ScopeIncludes tells whether a token holding the scope may act with the required one
+func ScopeIncludes(scope string, required string) bool {
+	return scopeRanks[scope] >= scopeRanks[required]
+}
+
+// HashAccessToken is what the database keeps in place of a personal access token
+func HashAccessToken(token string) string {
+	tokenHash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(tokenHash[:])
+}
+
+// AccessTokenClaims looks up a personal access token and returns the claims of a JWT standing in for it, the same `uId`
+// claim as one issued at sign in along with a `scope` claim holding the widest scope the token was given
+func AccessTokenClaims(token string) (map[string]interface{}, error) {
+	var tokenId, userId int
+	var scopes []string
+
+	query := `
+		UPDATE "deploy-io".access_tokens t SET last_used_at = CURRENT_TIMESTAMP
+		WHERE t.token_hash = $1 AND (t.expires_at IS NULL OR t.expires_at > CURRENT_TIMESTAMP)
+		RETURNING t.id, t.user_id, t.scopes;
+	`
+	err := config.DataBase.QueryRow(query, HashAccessToken(token)).Scan(&tokenId, &userId, pq.Array(&scopes))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, jwtauth.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	scope := ""
+	for _, candidate := range scopes {
+		if scopeRanks[candidate] > scopeRanks[scope] {
+			scope = candidate
+		}
+	}
+
+	if len(scope) == 0 {
+		return nil, jwtauth.ErrUnauthorized
+	}
+
+	return map[string]interface{}{"uId": int64(userId), "scope": scope, "tId": tokenId}, nil
+}
+
+// IsSessionActive is false once the session was revoked or ran out, or its user was disabled
+func IsSessionActive(sessionId int, userId int) bool {
+	var active bool
+
+	query := `
+		SELECT s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP AND COALESCE(u.status, true)
+		FROM "deploy-io".sessions s
+		JOIN "deploy-io".users u ON u.id = s.user_id
+		WHERE s.id = $1 AND s.user_id = $2
+	`
+	err := config.DataBase.QueryRow(query, sessionId, userId).Scan(&active)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			println("[AUTH] ", err.Error())
+		}
+		return false
+	}
+
+	return active
+}
+
+// RevokeSessions ends every session of the user, access tokens already issued stop working on their next request
+func RevokeSessions(userId int) error {
+	query := `UPDATE "deploy-io".sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := config.DataBase.Exec(query, userId)
+
+	return err
+}
+
+// SessionId reads the `sid` claim, tokens decoded from a request hold it as a float64
+func SessionId(claims map[string]interface{}) (int, bool) {
+	switch sessionId := claims["sid"].(type) {
+	case float64:
+		return int(sessionId), true
+	case int:
+		return sessionId, true
+	case int64:
+		return int(sessionId), true
+	}
+
+	return 0, false
+}
+
+func generateJWT(userId int64, sessionId int, ttl time.Duration) string {
 	tokenAuth := GetJWTAuthConfig()
 
-	_, tokenStr, _ := tokenAuth.Encode(map[string]interface{}{"uId": userId}) //,"exp": time.Now().AddDate(0, 1, 0)})
+	claims := map[string]interface{}{"uId": userId, "sid": sessionId}
+	jwtauth.SetIssuedNow(claims)
+	jwtauth.SetExpiryIn(claims, ttl)
+
+	_, tokenStr, _ := tokenAuth.Encode(claims)
 
 	return tokenStr
 }
 
+const oauthCookieName = "dio_oauth"
+
+const oauthStateTTL = 10 * time.Minute
+
+// signState returns `expiry.challenge.signature`, the state names the verifier it was issued with
+// so a state can not be paired with another browser's cookie
+func signState(verifier string, expiresAt time.Time) (string, error) {
+	payload := strconv.FormatInt(expiresAt.Unix(), 10) + "." + pkceChallenge(verifier)
+
+	signature, err := stateSignature(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return payload + "." + signature, nil
+}
+
+func verifyState(state string, verifier string) bool {
+	parts := strings.Split(state, ".")
+	if len(parts) != 3 || len(verifier) == 0 {
+		return false
+	}
+
+	signature, err := stateSignature(parts[0] + "." + parts[1])
+	if err != nil || !hmac.Equal([]byte(signature), []byte(parts[2])) {
+		return false
+	}
+
+	expiresAt, convErr := strconv.ParseInt(parts[0], 10, 64)
+	if convErr != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(parts[1]), []byte(pkceChallenge(verifier)))
+}
+
+func stateSignature(payload string) (string, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if len(strings.TrimSpace(jwtSecret)) == 0 {
+		return "", fmt.Errorf("[AUTH] JWT Secret was not recognized")
+	}
+
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("oauth-state:" + payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func pkceChallenge(verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(challenge[:])
+}
+
+// the dashboard calls the api from another site, the cookie has to be sent along with those requests
+func oauthCookie(verifier string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oauthCookieName,
+		Value:    verifier,
+		Path:     "/api/v1/auth",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	}
+}
+
+// getOauthURL lets GH_OAUTH_URL point sign in at another server, such as a fake one in tests
+func getOauthURL() string {
+	oauthURL := strings.TrimSpace(os.Getenv("GH_OAUTH_URL"))
+	if len(oauthURL) == 0 {
+		return "https://github.com"
+	}
+
+	return strings.TrimSuffix(oauthURL, "/")
+}
+
+// getAPIURL is GH_OAUTH_URL's counterpart for the api the user's name and email are read from
+func getAPIURL() string {
+	apiURL := strings.TrimSpace(os.Getenv("GH_API_URL"))
+	if len(apiURL) == 0 {
+		return "https://api.github.com"
+	}
+
+	return strings.TrimSuffix(apiURL, "/")
+}
+
+// getAccessTokenTTL is how long a JWT works before it has to be refreshed, JWT_TTL_MINUTES defaults to 15
+func getAccessTokenTTL() time.Duration {
+	minutes, convErr := strconv.Atoi(os.Getenv("JWT_TTL_MINUTES"))
+	if convErr != nil || minutes < 1 {
+		return 15 * time.Minute
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+// getSessionDays is how long a session can be refreshed after sign in, SESSION_TTL_DAYS defaults to 30
+func getSessionDays() int {
+	days, convErr := strconv.Atoi(os.Getenv("SESSION_TTL_DAYS"))
+	if convErr != nil || days < 1 {
+		return 30
+	}
+
+	return days
+}
+
 func GetJWTAuthConfig() *jwtauth.JWTAuth {
 	jwtSecret := os.Getenv("JWT_SECRET")
 
@@ -212,7 +665,7 @@ func FetchUserInfoFromGitHub(accessToken string) (*GhUserNameResponse, error) {
 		"Authorization": "Bearer " + accessToken,
 	}
 
-	resp, err := utils.Request("GET", "https://api.github.com/user", &headers, nil, nil)
+	resp, err := utils.Request("GET", getAPIURL()+"/user", &headers, nil, nil)
 	if err != nil {
 		fmt.Println("[AUTH] Error while calling GH's user API")
 		return nil, err
@@ -284,7 +737,7 @@ func getUserEmail(accessToken string) (*string, error) {
 		"Authorization": "Bearer " + accessToken,
 	}
 
-	resp, err := utils.Request("GET", "https://api.github.com/user/emails", &headers, nil, nil)
+	resp, err := utils.Request("GET", getAPIURL()+"/user/emails", &headers, nil, nil)
 	if err != nil {
 		fmt.Println("[AUTH] Error while calling GH's email API")
 		return nil, err
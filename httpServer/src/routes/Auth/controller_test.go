@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"httpServer/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// github is a fake GitHub, it hands out a token for "good-code" when the verifier matches the challenge
+// of the last authorize url and answers the email and user apis for that token
+type github struct {
+	mutex     sync.Mutex
+	challenge string
+	exchanges int
+}
+
+func (g *github) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/login/oauth/access_token":
+		g.exchanges++
+
+		query := r.URL.Query()
+		if query.Get("code") != "good-code" || pkceChallenge(query.Get("code_verifier")) != g.challenge {
+			fmt.Fprint(w, `{"error": "bad_verification_code", "error_description": "The code passed is incorrect or expired."}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"access_token": "gh-token", "refresh_token": "gh-refresh", "expires_in": 28800, "refresh_token_expires_in": 15897600}`)
+	case "/user/emails":
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `[{"email": "octo@example.com", "primary": true, "verified": true}]`)
+	case "/user":
+		fmt.Fprint(w, `{"name": "Octo"}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "auth-test-secret")
+	os.Setenv("GH_CLIENT_ID", "client")
+	os.Setenv("GH_CLIENT_SECRET", "secret")
+
+	sql.Register("authtest", fakeDriver{})
+
+	db, err := sql.Open("authtest", "")
+	if err != nil {
+		panic(err)
+	}
+	config.DataBase = db
+
+	os.Exit(m.Run())
+}
+
+func TestSignIn(t *testing.T) {
+	gh := &github{}
+	server := httptest.NewServer(gh)
+	defer server.Close()
+
+	t.Setenv("GH_OAUTH_URL", server.URL)
+	t.Setenv("GH_API_URL", server.URL)
+
+	router := AuthRouter()
+
+	authorize := func() (string, *http.Cookie) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/authorize", nil))
+
+		var body map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		authorizeURL, err := url.Parse(body["url"])
+		if err != nil || !strings.HasPrefix(body["url"], server.URL+"/login/oauth/authorize") {
+			t.Fatalf("authorize url %q does not point at GH_OAUTH_URL", body["url"])
+		}
+
+		gh.mutex.Lock()
+		gh.challenge = authorizeURL.Query().Get("code_challenge")
+		gh.mutex.Unlock()
+
+		return body["state"], w.Result().Cookies()[0]
+	}
+
+	signIn := func(state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"code": "good-code", "state": %q}`, state)
+		r := httptest.NewRequest("POST", "/signin", strings.NewReader(body))
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("valid state and verifier", func(t *testing.T) {
+		state, cookie := authorize()
+
+		w := signIn(state, cookie)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["token"] == nil || body["refresh_token"] == nil {
+			t.Errorf("no tokens in %s", w.Body.String())
+		}
+	})
+
+	t.Run("tampered state", func(t *testing.T) {
+		state, cookie := authorize()
+		parts := strings.Split(state, ".")
+		parts[0] = fmt.Sprint(time.Now().Add(time.Hour).Unix())
+
+		expectRefused(t, gh, func() *httptest.ResponseRecorder { return signIn(strings.Join(parts, "."), cookie) })
+	})
+
+	t.Run("expired state", func(t *testing.T) {
+		_, cookie := authorize()
+
+		state, err := signState(cookie.Value, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectRefused(t, gh, func() *httptest.ResponseRecorder { return signIn(state, cookie) })
+	})
+
+	t.Run("verifier of another sign in", func(t *testing.T) {
+		state, _ := authorize()
+		_, otherCookie := authorize()
+
+		expectRefused(t, gh, func() *httptest.ResponseRecorder { return signIn(state, otherCookie) })
+	})
+
+	t.Run("no verifier", func(t *testing.T) {
+		state, _ := authorize()
+
+		expectRefused(t, gh, func() *httptest.ResponseRecorder { return signIn(state, nil) })
+	})
+
+	t.Run("verifier GitHub did not issue the code for", func(t *testing.T) {
+		state, cookie := authorize()
+		// GitHub now expects the challenge of a later sign in, the matching state and cookie get past
+		// the state check and the exchange itself fails
+		authorize()
+
+		w := signIn(state, cookie)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+}
+
+// expectRefused checks that sign in failed before the code was sent to GitHub
+func expectRefused(t *testing.T, gh *github, signIn func() *httptest.ResponseRecorder) {
+	t.Helper()
+
+	gh.mutex.Lock()
+	before := gh.exchanges
+	gh.mutex.Unlock()
+
+	w := signIn()
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+
+	gh.mutex.Lock()
+	defer gh.mutex.Unlock()
+
+	if gh.exchanges != before {
+		t.Errorf("the code was sent to GitHub")
+	}
+}
+
+var errUnexpectedQuery = errors.New("query is not answered by the test database")
+
+// fakeDriver plays a database holding the user octo@example.com, enough for SignIn to finish
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errUnexpectedQuery }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if strings.Contains(s.query, "SET access = $1") {
+		return driver.RowsAffected(1), nil
+	}
+
+	return nil, errUnexpectedQuery
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.Contains(s.query, `SELECT id FROM "deploy-io".users WHERE email`):
+		if args[0] == "octo@example.com" {
+			return &fakeRows{values: []driver.Value{int64(7)}}, nil
+		}
+		return &fakeRows{}, nil
+	case strings.Contains(s.query, `SELECT u.status FROM "deploy-io".users u`):
+		return &fakeRows{values: []driver.Value{true}}, nil
+	case strings.Contains(s.query, `INSERT INTO "deploy-io".sessions`):
+		return &fakeRows{values: []driver.Value{int64(3)}}, nil
+	}
+
+	return nil, errUnexpectedQuery
+}
+
+// fakeRows holds one row of values, or none when values is empty
+type fakeRows struct {
+	values []driver.Value
+	read   bool
+}
+
+func (r *fakeRows) Columns() []string { return make([]string, len(r.values)) }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.read || len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values)
+	r.read = true
+
+	return nil
+}
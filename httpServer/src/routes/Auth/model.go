@@ -6,6 +6,13 @@ type AuthHandler struct{}
 
 type UserSignInPayload struct {
 	Code         string `json:"code"`
+	State        string `json:"state"`
+	RefreshToken string `json:"refresh_token"`
+	// read from the cookie Authorize set, never from the request body
+	CodeVerifier string `json:"-"`
+}
+
+type RefreshSessionBody struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
@@ -45,3 +52,20 @@ type User struct {
 	Refresh_expires_by time.Duration
 	Name               string
 }
+
+// personal access tokens start with this so they can be told apart from the JWTs issued at sign in
+const AccessTokenPrefix = "dio_"
+
+// every scope can do what the scopes before it can, read is limited to the viewer role,
+// deploy to the developer role and admin to whatever role the user holds
+const (
+	ScopeRead   = "read"
+	ScopeDeploy = "deploy"
+	ScopeAdmin  = "admin"
+)
+
+var scopeRanks = map[string]int{
+	ScopeRead:   1,
+	ScopeDeploy: 2,
+	ScopeAdmin:  3,
+}
@@ -1,6 +1,7 @@
 package build
 
 import (
+	"httpServer/src/access"
 	"httpServer/src/middleware"
 	auth "httpServer/src/routes/Auth"
 
@@ -14,14 +15,13 @@ func BuildRouter() chi.Router {
 	u := BuildHandler{}
 
 	r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
 		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
 
-		r.Use(middleware.GithubTokenValidation)
-
-		r.Post("/new", u.CreateBuild)
-		r.Get("/all/{id}", u.ListBuilds)
-		r.Get("/{id}", u.Build)
+		r.With(middleware.ProjectBody(access.RoleDeveloper)).Post("/new", u.CreateBuild)
+		r.With(middleware.ProjectParam("id", access.RoleViewer)).Get("/all/{id}", u.ListBuilds)
+		r.With(middleware.BuildParam("id", access.RoleViewer)).Get("/{id}", u.Build)
 	})
 
 	return r
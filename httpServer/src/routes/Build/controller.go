@@ -2,9 +2,11 @@ package build
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"httpServer/config"
+	"httpServer/src/gitprovider"
 	auth "httpServer/src/routes/Auth"
 	"httpServer/utils"
 	"io"
@@ -34,19 +36,30 @@ func (b BuildHandler) CreateBuild(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
-	githubId, dbErr := getGithubId(requestBody.ProjectId, *userId)
+	repo, dbErr := getRepository(requestBody.ProjectId)
 	if dbErr != nil {
 		utils.HandleError(utils.ErrInvalid, dbErr, w, nil)
 		return
 	}
 
-	commitSha, shaErr := getCommitSha(*githubId, *userId)
+	// repositories the GitHub App is installed on are read with its token, the others with the token of the
+	// user who connected the project, which may not be the one asking for the build
+	commitSha, shaErr := getCommitSha(repo)
+	if shaErr == auth.ErrGithubTokenExpired {
+		userId := utils.GetUserIdFromContext(w, r)
+		if userId == nil {
+			return
+		}
+
+		if *userId == repo.UserId {
+			utils.HandleError(utils.TokenExpired, shaErr, w, nil)
+			return
+		}
+
+		errString := "[BUILD] the GitHub sign in of the user who connected the project has expired"
+		utils.HandleError(utils.ErrInvalid, shaErr, w, &errString)
+		return
+	}
 	if shaErr != nil {
 		errString := "[BUILD] error while requesting commit sha"
 		utils.HandleError(utils.ErrInvalid, shaErr, w, &errString)
@@ -58,7 +71,7 @@ func (b BuildHandler) CreateBuild(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buildId, buildInsertErr := insertIntoDB(requestBody.ProjectId, commitSha)
+	buildId, buildInsertErr := insertIntoDB(requestBody.ProjectId, commitSha, "manual", requestBody.Canary)
 	if buildInsertErr != nil || buildId == nil {
 		utils.HandleError(utils.ErrInvalid, buildInsertErr, w, nil)
 		return
@@ -74,113 +87,203 @@ func (b BuildHandler) CreateBuild(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buildCtx := context.Background()
-
-	queueErr := config.RabbitChannel.PublishWithContext(buildCtx, "", config.RabbitQueue.Name, false, false, amqp091.Publishing{
-		ContentType: "application/octet-stream",
-		Body:        []byte(responseBody),
-	})
+	queueErr := publish(*buildId, responseBody)
 	if queueErr != nil {
-		UpdateBuildLog(*buildId, queueErr.Error())
-		SetBuildStatus(*buildId, "failure")
 		utils.HandleError(utils.ErrInternal, queueErr, w, nil)
 		return
 	}
 
-	log.Printf("[rabbitMQ] sent %s", responseBody)
-
 	w.Write(responseBody)
 }
 
-func insertIntoDB(projectId int, commitSha string) (*int, error) {
-	var buildId int
+// Rebuild queues a build of the latest commit on the project's repository, used when its settings change
+func Rebuild(projectId int) (*int, error) {
+	repo, dbErr := getRepository(projectId)
+	if dbErr != nil {
+		return nil, dbErr
+	}
 
-	insertQuery := `
-		INSERT INTO "deploy-io".builds(project_id, status, triggered_by, commit_hash)
-		VALUES($1, 'in queue', 'manual', $2) RETURNING id
-	`
-	insertErr := config.DataBase.QueryRow(insertQuery, projectId, commitSha).Scan(&buildId)
-	if insertErr != nil {
-		return nil, insertErr
+	commitSha, shaErr := getCommitSha(repo)
+	if shaErr != nil {
+		return nil, shaErr
 	}
 
-	return &buildId, nil
+	if len(commitSha) <= 0 {
+		return nil, fmt.Errorf("[BUILD] Sha doesn't exists")
+	}
+
+	buildId, buildInsertErr := insertIntoDB(projectId, commitSha, "manual", false)
+	if buildInsertErr != nil {
+		return nil, buildInsertErr
+	}
+
+	message, constructorErr := json.Marshal(map[string]int{"build_id": *buildId})
+	if constructorErr != nil {
+		return nil, constructorErr
+	}
+
+	if queueErr := publish(*buildId, message); queueErr != nil {
+		return nil, queueErr
+	}
+
+	return buildId, nil
 }
 
-func getGithubId(projectId int, userId int) (*int, error) {
-	var githubId int
+// PushBuild queues a build of the pushed commit for every project connected to the repository,
+// suspended projects are skipped. Projects are queued independently, one that fails is logged and
+// the others still get their build, an error is only returned when the projects could not be looked up
+func PushBuild(provider string, repoId string, commitSha string) ([]int, error) {
+	query := `SELECT p.id FROM "deploy-io".projects p WHERE p.provider = $1 AND p.repo = $2 AND p.suspended = FALSE`
+	rows, queryErr := config.DataBase.Query(query, provider, repoId)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	var projectIds []int
 
-	searchQuery := `SELECT github_id FROM "deploy-io".projects p WHERE p.id = $1 AND p.user_id = $2`
+	for rows.Next() {
+		var projectId int
+		if err := rows.Scan(&projectId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		projectIds = append(projectIds, projectId)
+	}
+	rows.Close()
 
-	searchErr := config.DataBase.QueryRow(searchQuery, projectId, userId).Scan(&githubId)
-	if searchErr != nil {
-		return nil, searchErr
+	buildIds := []int{}
+
+	for _, projectId := range projectIds {
+		buildId, buildErr := ProjectPushBuild(projectId, commitSha)
+		if buildErr != nil {
+			log.Printf("[BUILD] could not queue the push build of project %d: %s", projectId, buildErr.Error())
+			continue
+		}
+
+		buildIds = append(buildIds, *buildId)
 	}
 
-	return &githubId, nil
+	return buildIds, nil
 }
 
-func getCommitSha(githubId int, userId int) (string, error) {
-	accessToken, accessTokenErr := auth.GetAccessToken(userId)
-	if accessTokenErr != nil {
-		return "", accessTokenErr
+// ProjectPushBuild queues a build of the pushed commit for a single project, for webhooks set up on one project
+func ProjectPushBuild(projectId int, commitSha string) (*int, error) {
+	buildId, buildInsertErr := insertIntoDB(projectId, commitSha, "push", false)
+	if buildInsertErr != nil {
+		return nil, buildInsertErr
 	}
 
-	header := map[string]string{
-		"Authorization": `Bearer ` + *accessToken,
+	message, constructorErr := json.Marshal(map[string]int{"build_id": *buildId})
+	if constructorErr != nil {
+		return nil, constructorErr
 	}
 
-	repoResponse, repoErr := utils.Request("GET", fmt.Sprintf("https://api.github.com/repositories/%d", githubId), &header, nil, nil)
-	if repoErr != nil {
-		return "", repoErr
+	if queueErr := publish(*buildId, message); queueErr != nil {
+		return nil, queueErr
 	}
 
-	defer repoResponse.Body.Close()
+	return buildId, nil
+}
 
-	repoResponseBody, readErr := io.ReadAll(repoResponse.Body)
-	if readErr != nil {
-		return "", readErr
+// Requeue sends an existing build back to the build server, only failed builds and ones stuck in the queue can be requeued.
+// sql.ErrNoRows is returned when the build does not exist or is in neither state
+func Requeue(buildId int) error {
+	query := `
+		UPDATE "deploy-io".builds SET status = 'in queue', logs = NULL, start_time = NULL, end_time = NULL
+		WHERE id = $1 AND status IN ('in queue', 'failure')
+		AND project_id IN (SELECT p.id FROM "deploy-io".projects p WHERE p.suspended = FALSE)
+	`
+	result, updateErr := config.DataBase.Exec(query, buildId)
+	if updateErr != nil {
+		return updateErr
 	}
 
-	var repoAPIResponse RepoAPIResponse
+	rowsAffected, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return rowsErr
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
 
-	deconstructorErr := json.Unmarshal(repoResponseBody, &repoAPIResponse)
-	if deconstructorErr != nil {
-		return "", deconstructorErr
+	message, constructorErr := json.Marshal(map[string]int{"build_id": buildId})
+	if constructorErr != nil {
+		return constructorErr
 	}
 
-	commitsURL := repoAPIResponse.CommitsURL
+	return publish(buildId, message)
+}
+
+// publish hands the build to the build server, a build that could not be queued is marked as failed
+func publish(buildId int, message []byte) error {
+	buildCtx := context.Background()
 
-	if len(commitsURL) <= 0 {
-		return "", fmt.Errorf("[BUILD] Could not get commits url")
+	queueErr := config.RabbitChannel.PublishWithContext(buildCtx, "", config.RabbitQueue.Name, false, false, amqp091.Publishing{
+		ContentType: "application/octet-stream",
+		Body:        message,
+	})
+	if queueErr != nil {
+		UpdateBuildLog(buildId, queueErr.Error())
+		SetBuildStatus(buildId, "failure")
+		return queueErr
 	}
 
-	commitsURL = strings.ReplaceAll(repoAPIResponse.CommitsURL, "{/sha}", "")
+	log.Printf("[rabbitMQ] sent %s", message)
 
-	shaResponse, shaErr := utils.Request("GET", commitsURL, &header, nil, nil)
-	if shaErr != nil {
-		return "", repoErr
+	return nil
+}
+
+func insertIntoDB(projectId int, commitSha string, triggeredBy string, canary bool) (*int, error) {
+	var buildId int
+
+	insertQuery := `
+		INSERT INTO "deploy-io".builds(project_id, status, triggered_by, commit_hash, canary)
+		VALUES($1, 'in queue', $2, $3, $4) RETURNING id
+	`
+	insertErr := config.DataBase.QueryRow(insertQuery, projectId, triggeredBy, commitSha, canary).Scan(&buildId)
+	if insertErr != nil {
+		return nil, insertErr
 	}
 
-	defer shaResponse.Body.Close()
+	return &buildId, nil
+}
+
+// getRepository returns the project's repository and the user whose GitHub access connected it,
+// members of an organization build with that access rather than their own
+func getRepository(projectId int) (*gitprovider.Repo, error) {
+	var repo gitprovider.Repo
+	var host, token *string
+
+	// a suspended project keeps its repository connected but is not built until an admin lifts the suspension
+	searchQuery := `SELECT p.provider, p.repo, p.repo_host, p.repo_token, p.user_id FROM "deploy-io".projects p WHERE p.id = $1 AND p.suspended = FALSE`
 
-	shaResponseBody, shaReadErr := io.ReadAll(shaResponse.Body)
-	if shaReadErr != nil {
-		return "", readErr
+	searchErr := config.DataBase.QueryRow(searchQuery, projectId).Scan(&repo.Provider, &repo.Id, &host, &token, &repo.UserId)
+	if searchErr != nil {
+		return nil, searchErr
 	}
 
-	var shaAPIResponse []CommitObject
+	if host != nil {
+		repo.Host = *host
+	}
 
-	shaDeconstructorErr := json.Unmarshal(shaResponseBody, &shaAPIResponse)
-	if shaDeconstructorErr != nil {
-		return "", deconstructorErr
+	if token != nil {
+		decrypted, decryptErr := gitprovider.Decrypt(*token)
+		if decryptErr != nil {
+			return nil, decryptErr
+		}
+		repo.Token = decrypted
 	}
 
-	if len(shaAPIResponse) <= 0 {
-		return "", fmt.Errorf("[BUILD] No commits have been made")
+	return &repo, nil
+}
+
+func getCommitSha(repo *gitprovider.Repo) (string, error) {
+	provider, providerErr := gitprovider.For(repo.Provider)
+	if providerErr != nil {
+		return "", providerErr
 	}
 
-	return shaAPIResponse[0].Sha, nil
+	return provider.ResolveRef(*repo, "")
 }
 
 func (b BuildHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
@@ -204,19 +307,12 @@ func (b BuildHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
 
 	offset := ((pageNumber - 1) * limit)
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	var listBuilds []Build
 
-	listBuildQuery := `SELECT b.id, b.status, b.triggered_by, b.commit_hash, b.created_at FROM "deploy-io".builds b
-		JOIN "deploy-io".projects p ON p.id = b.project_id
-		WHERE b.project_id = $1 AND p.user_id = $2 ORDER BY b.id DESC LIMIT $3 OFFSET $4;
+	listBuildQuery := `SELECT b.id, b.status, b.triggered_by, b.commit_hash, b.canary, b.created_at FROM "deploy-io".builds b
+		WHERE b.project_id = $1 ORDER BY b.id DESC LIMIT $2 OFFSET $3;
 	`
-	builds, rowsErr := config.DataBase.Query(listBuildQuery, projectId, userId, limit, offset)
+	builds, rowsErr := config.DataBase.Query(listBuildQuery, projectId, limit, offset)
 	if rowsErr != nil {
 		utils.HandleError(utils.ErrInternal, rowsErr, w, nil)
 		return
@@ -224,7 +320,7 @@ func (b BuildHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
 
 	for builds.Next() {
 		var build Build
-		builds.Scan(&build.Id, &build.Build_status, &build.Triggered_by, &build.Commit_hash, &build.Created_at)
+		builds.Scan(&build.Id, &build.Build_status, &build.Triggered_by, &build.Commit_hash, &build.Canary, &build.Created_at)
 
 		listBuilds = append(listBuilds, build)
 	}
@@ -233,9 +329,8 @@ func (b BuildHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
 
 	countErr := config.DataBase.QueryRow(`
 		SELECT COUNT(*) FROM "deploy-io".builds b
-		JOIN "deploy-io".projects p ON p.id = b.project_id
-		WHERE b.project_id = $1 AND p.user_id = $2;
-	`, projectId, userId).Scan(&totalItems)
+		WHERE b.project_id = $1;
+	`, projectId).Scan(&totalItems)
 
 	if countErr != nil {
 		utils.HandleError(utils.ErrInternal, countErr, w, nil)
@@ -268,16 +363,10 @@ func (b BuildHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
 func (b BuildHandler) Build(w http.ResponseWriter, r *http.Request) {
 	buildId := chi.URLParam(r, "id")
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	var build Build
 
-	buildQuery := `SELECT id, status, triggered_by, commit_hash, logs, start_time, end_time, created_at, updated_at FROM "deploy-io".builds b WHERE b.id = $1`
-	rowsErr := config.DataBase.QueryRow(buildQuery, buildId).Scan(&build.Id, &build.Build_status, &build.Triggered_by, &build.Commit_hash, &build.Build_logs, &build.Start_time, &build.End_time, &build.Created_at, &build.Updated_at)
+	buildQuery := `SELECT id, status, triggered_by, commit_hash, canary, logs, start_time, end_time, created_at, updated_at FROM "deploy-io".builds b WHERE b.id = $1`
+	rowsErr := config.DataBase.QueryRow(buildQuery, buildId).Scan(&build.Id, &build.Build_status, &build.Triggered_by, &build.Commit_hash, &build.Canary, &build.Build_logs, &build.Start_time, &build.End_time, &build.Created_at, &build.Updated_at)
 	if rowsErr != nil {
 		if strings.Contains(rowsErr.Error(), "no rows in result set") {
 			w.WriteHeader(404)
@@ -299,7 +388,7 @@ func (b BuildHandler) Build(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateBuildLog(buildId int, log string) error {
-	query := `UPDATE "deploy-io".builds SET logs = COALESCE(logs || E'\n', '') || '$1', end_time = $2 WHERE id = $3`
+	query := `UPDATE "deploy-io".builds SET logs = COALESCE(logs || E'\n', '') || $1, end_time = $2 WHERE id = $3`
 	_, queErr := config.DataBase.Exec(query, log, time.Now(), buildId)
 	if queErr != nil {
 		return queErr
@@ -309,7 +398,7 @@ func UpdateBuildLog(buildId int, log string) error {
 }
 
 func SetBuildStatus(buildId int, status string) error {
-	query := `UPDATE "deploy-io".builds SET status = '$1' WHERE id = $2`
+	query := `UPDATE "deploy-io".builds SET status = $1 WHERE id = $2`
 	_, queErr := config.DataBase.Exec(query, status, buildId)
 	if queErr != nil {
 		return queErr
@@ -7,14 +7,8 @@ type BuildHandler struct{}
 // CreateBuild
 type InsertBuildBody struct {
 	ProjectId int `json:"project_id"`
-}
-
-type RepoAPIResponse struct {
-	CommitsURL string `json:"commits_url"`
-}
-
-type CommitObject struct {
-	Sha string `json:"sha"`
+	// deploys the build as a candidate next to the active deployment instead of replacing it
+	Canary bool `json:"canary"`
 }
 
 // ListBuilds
@@ -23,6 +17,7 @@ type Build struct {
 	Build_status string     `json:"build_status"`
 	Triggered_by string     `json:"triggered_by"`
 	Commit_hash  string     `json:"commit_hash"`
+	Canary       bool       `json:"canary"`
 	Build_logs   *string    `json:"build_logs,omitempty"`
 	Start_time   *time.Time `json:"start_time,omitempty"`
 	End_time     *time.Time `json:"end_time,omitempty"`
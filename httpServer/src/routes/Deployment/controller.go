@@ -59,12 +59,6 @@ func (DeploymentHandler) Deployment(w http.ResponseWriter, r *http.Request) {
 }
 
 func (DeploymentHandler) DeleteDeployment(w http.ResponseWriter, r *http.Request) {
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	requestBody, readErr := io.ReadAll(r.Body)
 	if readErr != nil {
 		utils.HandleError(utils.ErrInternal, readErr, w, nil)
@@ -88,7 +82,7 @@ func (DeploymentHandler) DeleteDeployment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	delErr := DeleteFiles(ProjectName)
+	delErr := DeleteFiles(ProjectId, ProjectName)
 	if delErr != nil {
 		utils.HandleError(utils.ErrInternal, delErr, w, nil)
 		return
@@ -115,12 +109,6 @@ func (DeploymentHandler) DeleteDeployment(w http.ResponseWriter, r *http.Request
 }
 
 func (DeploymentHandler) ListDeployments(w http.ResponseWriter, r *http.Request) {
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	requestBody, readErr := io.ReadAll(r.Body)
 	if readErr != nil {
 		utils.HandleError(utils.ErrInternal, readErr, w, nil)
@@ -134,8 +122,8 @@ func (DeploymentHandler) ListDeployments(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	query := `SELECT d.id, d.build_id, d.status, d.created_at FROM "deploy-io".deployments d JOIN "deploy-io".projects p on d.project_id = p.id WHERE d.project_id = $1 AND p.user_id = $2`
-	rows, qErr := config.DataBase.Query(query, Request.ProjectId, *userId)
+	query := `SELECT d.id, d.build_id, d.status, d.created_at FROM "deploy-io".deployments d WHERE d.project_id = $1`
+	rows, qErr := config.DataBase.Query(query, Request.ProjectId)
 	if qErr != nil {
 		utils.HandleError(utils.ErrInternal, qErr, w, nil)
 		return
@@ -164,14 +152,48 @@ func (DeploymentHandler) ListDeployments(w http.ResponseWriter, r *http.Request)
 	w.Write(responseBody)
 }
 
-func DeleteFiles(projectName string) error {
+// DeleteFiles removes the files of every build of the project, along with the ones
+// stored under the project name before each build got its own prefix
+func DeleteFiles(projectId int, projectName string) error {
+	query := `SELECT b.id FROM "deploy-io".builds b WHERE b.project_id = $1 AND b.artifacts_kept = TRUE`
+	rows, qErr := config.DataBase.Query(query, projectId)
+	if qErr != nil {
+		return qErr
+	}
+
+	prefixes := []string{projectName + "/"}
+
+	for rows.Next() {
+		var buildId int
+		if err := rows.Scan(&buildId); err != nil {
+			rows.Close()
+			return err
+		}
+		prefixes = append(prefixes, fmt.Sprintf("_builds/%d/", buildId), fmt.Sprintf("_functions/%d/", buildId))
+	}
+	rows.Close()
+
+	for _, prefix := range prefixes {
+		if err := DeletePrefix(prefix); err != nil {
+			return err
+		}
+	}
+
+	updateQuery := `UPDATE "deploy-io".builds SET artifacts_kept = FALSE WHERE project_id = $1`
+	_, updateErr := config.DataBase.Exec(updateQuery, projectId)
+
+	return updateErr
+}
+
+// DeletePrefix removes every object stored under the prefix
+func DeletePrefix(prefix string) error {
 	bucketName, bucketExists := os.LookupEnv("MIO_BUCKET")
 	if !bucketExists {
 		return fmt.Errorf("[DEPLOYMENT] bucket name was not set in env variable")
 	}
 
 	var objects []minio.ObjectInfo
-	for object := range config.Minio.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{Prefix: projectName, Recursive: true}) {
+	for object := range config.Minio.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
 		if object.Err != nil {
 			return object.Err
 		}
@@ -189,6 +211,54 @@ func DeleteFiles(projectName string) error {
 	return nil
 }
 
+// CopyPrefix copies every object stored under one prefix to the same path under another,
+// the source is left in place so a failed copy never loses files. The keys written so far are
+// returned along with an error, so the caller can remove exactly what this copy created
+func CopyPrefix(from string, to string) ([]string, error) {
+	bucketName, bucketExists := os.LookupEnv("MIO_BUCKET")
+	if !bucketExists {
+		return nil, fmt.Errorf("[DEPLOYMENT] bucket name was not set in env variable")
+	}
+
+	var copied []string
+
+	for object := range config.Minio.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{Prefix: from, Recursive: true}) {
+		if object.Err != nil {
+			return copied, object.Err
+		}
+
+		destination := minio.CopyDestOptions{Bucket: bucketName, Object: to + strings.TrimPrefix(object.Key, from)}
+		source := minio.CopySrcOptions{Bucket: bucketName, Object: object.Key}
+
+		if _, err := config.Minio.CopyObject(context.Background(), destination, source); err != nil {
+			return copied, err
+		}
+
+		copied = append(copied, destination.Object)
+	}
+
+	return copied, nil
+}
+
+// DeleteObjects removes the objects by key, unlike DeletePrefix it leaves everything else under the prefix alone.
+// A key that can not be removed does not stop the others, the first error is returned once all were tried
+func DeleteObjects(keys []string) error {
+	bucketName, bucketExists := os.LookupEnv("MIO_BUCKET")
+	if !bucketExists {
+		return fmt.Errorf("[DEPLOYMENT] bucket name was not set in env variable")
+	}
+
+	var firstErr error
+
+	for _, key := range keys {
+		if err := removeObject(minio.ObjectInfo{Key: key}, bucketName); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
 func removeObject(object minio.ObjectInfo, bucketName string) error {
 	opts := minio.RemoveObjectOptions{
 		GovernanceBypass: true,
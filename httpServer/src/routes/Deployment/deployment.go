@@ -1,6 +1,8 @@
 package deployment
 
 import (
+	"httpServer/src/access"
+	"httpServer/src/middleware"
 	auth "httpServer/src/routes/Auth"
 
 	"github.com/go-chi/chi/v5"
@@ -13,12 +15,13 @@ func DeploymentRouter() chi.Router {
 	d := DeploymentHandler{}
 
 	r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
 		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
 
-		r.Get("/all", d.ListDeployments)
-		r.Get("/{id}", d.Deployment)
-		r.Delete("/deactivate", d.DeleteDeployment)
+		r.With(middleware.ProjectBody(access.RoleViewer)).Get("/all", d.ListDeployments)
+		r.With(middleware.ProjectParam("id", access.RoleViewer)).Get("/{id}", d.Deployment)
+		r.With(middleware.ProjectBody(access.RoleAdmin)).Delete("/deactivate", d.DeleteDeployment)
 	})
 
 	return r
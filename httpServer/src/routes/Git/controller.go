@@ -0,0 +1,146 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"httpServer/config"
+	"httpServer/src/gitprovider"
+	build "httpServer/src/routes/Build"
+	"httpServer/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (g GitHandler) ListRepositories(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody ListRepositoriesBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	provider, providerErr := gitprovider.For(requestBody.Provider)
+	if providerErr != nil {
+		errMsg := providerErr.Error()
+		utils.HandleError(utils.ErrInvalid, providerErr, w, &errMsg)
+		return
+	}
+
+	repo := gitprovider.Repo{
+		Provider: requestBody.Provider,
+		Host:     strings.TrimSpace(requestBody.Host),
+		Token:    strings.TrimSpace(requestBody.Token),
+		UserId:   *userId,
+	}
+
+	repositories, listErr := provider.ListRepositories(repo)
+	if listErr == gitprovider.ErrUnsupported {
+		errMsg := "[GIT] plain git repositories are connected by their url"
+		utils.HandleError(utils.ErrInvalid, listErr, w, &errMsg)
+		return
+	}
+	if listErr != nil {
+		errMsg := "[GIT] could not list repositories"
+		utils.HandleError(utils.ErrInvalid, listErr, w, &errMsg)
+		return
+	}
+
+	response, constructorErr := json.Marshal(map[string][]gitprovider.Repository{
+		"repositories": repositories,
+	})
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Webhook receives the deliveries of a GitLab or Gitea project, a push to the default branch builds it.
+// Deliveries are acknowledged with 202 whether or not they start a build
+func (g GitHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	projectId, convErr := strconv.Atoi(chi.URLParam(r, "id"))
+	if convErr != nil {
+		utils.HandleError(utils.ErrNotFound, convErr, w, nil)
+		return
+	}
+
+	body, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		utils.HandleError(utils.ErrInvalid, readErr, w, nil)
+		return
+	}
+
+	var providerName string
+	var secret *string
+	var suspended bool
+
+	query := `SELECT p.provider, p.webhook_secret, p.suspended FROM "deploy-io".projects p WHERE p.id = $1`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&providerName, &secret, &suspended)
+	if queryErr != nil || secret == nil {
+		// unknown projects and ones without a webhook answer the same as a bad signature
+		utils.HandleError(utils.ErrUnAuthorized, queryErr, w, nil)
+		return
+	}
+
+	decryptedSecret, decryptErr := gitprovider.Decrypt(*secret)
+	if decryptErr != nil {
+		utils.HandleError(utils.ErrInternal, decryptErr, w, nil)
+		return
+	}
+
+	provider, providerErr := gitprovider.For(providerName)
+	if providerErr != nil {
+		utils.HandleError(utils.ErrInternal, providerErr, w, nil)
+		return
+	}
+
+	push, verifyErr := provider.VerifyWebhook(r, body, decryptedSecret)
+	if verifyErr == gitprovider.ErrUnverified || verifyErr == gitprovider.ErrUnsupported {
+		utils.HandleError(utils.ErrUnAuthorized, nil, w, nil)
+		return
+	}
+	if verifyErr != nil {
+		utils.HandleError(utils.ErrInvalid, verifyErr, w, nil)
+		return
+	}
+
+	// other events, pushes to other branches and suspended projects have nothing to deploy
+	if push == nil || suspended {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	buildId, buildErr := build.ProjectPushBuild(projectId, push.Sha)
+	if buildErr != nil {
+		utils.HandleError(utils.ErrInternal, buildErr, w, nil)
+		return
+	}
+
+	fmt.Printf("[GIT] push to project %d queued build %d\n", projectId, *buildId)
+
+	response, constructorErr := json.Marshal(map[string]int{"build_id": *buildId})
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(response)
+}
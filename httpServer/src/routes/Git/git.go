@@ -0,0 +1,29 @@
+package git
+
+import (
+	"httpServer/src/middleware"
+	auth "httpServer/src/routes/Auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// GitRouter lists repositories of any git provider and receives the webhooks of GitLab and Gitea projects
+func GitRouter() chi.Router {
+	r := chi.NewRouter()
+
+	g := GitHandler{}
+
+	// deliveries are checked against the project's webhook secret, they carry no user token
+	r.Post("/webhook/{id}", g.Webhook)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
+
+		r.Post("/repos", g.ListRepositories)
+	})
+
+	return r
+}
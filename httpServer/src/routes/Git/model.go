@@ -0,0 +1,10 @@
+package git
+
+type GitHandler struct{}
+
+// the token is only used for the listing, it is not stored
+type ListRepositoriesBody struct {
+	Provider string `json:"provider"`
+	Host     string `json:"host"`
+	Token    string `json:"token"`
+}
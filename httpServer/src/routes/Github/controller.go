@@ -3,10 +3,14 @@ package github
 import (
 	"encoding/json"
 	"fmt"
+	"httpServer/src/gitprovider"
 	auth "httpServer/src/routes/Auth"
+	build "httpServer/src/routes/Build"
 	"httpServer/utils"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
 )
 
 func (gHandler GithubHandler) ListUserRepositories(w http.ResponseWriter, r *http.Request) {
@@ -16,90 +20,75 @@ func (gHandler GithubHandler) ListUserRepositories(w http.ResponseWriter, r *htt
 		return
 	}
 
-	repositories, err := getUserRepositories(*userId)
-	if err != nil {
-		utils.HandleError(utils.ErrInternal, err, w, nil)
+	repositories, err := gitprovider.GitHub{}.ListRepositories(gitprovider.Repo{UserId: *userId})
+	if err == auth.ErrGithubTokenExpired {
+		utils.HandleError(utils.TokenExpired, err, w, nil)
 		return
 	}
-
-	body, err := json.Marshal(repositories)
 	if err != nil {
 		utils.HandleError(utils.ErrInternal, err, w, nil)
 		return
 	}
 
-	w.Write([]byte(body))
-}
-
-func getUserRepositories(userId int) (*RepoAPIResponse, error) {
-
-	accessToken, err := auth.GetAccessToken(userId)
-	if err != nil {
-		fmt.Println("[GITHUB] Error while reading access token")
-		return nil, err
-	}
+	// the dashboard reads GitHub's own shape, numeric ids and the avatar under owner
+	response := RepoAPIResponse{}
+	for _, repository := range repositories {
+		githubId, _ := strconv.Atoi(repository.Id)
 
-	header := map[string]string{
-		"Authorization": `Bearer ` + *accessToken,
-	}
+		item := RepoAPIResponseItem{ID: githubId, FullName: repository.FullName}
+		item.Owner.AvatarURL = repository.AvatarURL
 
-	resp, err := utils.Request("GET", "https://api.github.com/user/repos", &header, nil, nil)
-	if err != nil {
-		fmt.Println("[GITHUB] Error while requesting for user repositories")
-		return nil, err
+		response = append(response, item)
 	}
 
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
+	body, err := json.Marshal(response)
 	if err != nil {
-		fmt.Println("[GITHUB] Error while reading response from user repositories endpoint")
-		return nil, err
-	}
-
-	var RepoAPIResponse RepoAPIResponse
-
-	err = json.Unmarshal(respBody, &RepoAPIResponse)
-	if err != nil {
-		fmt.Println("[GITHUB] Error while reading response from user repositories response")
-		return nil, err
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
 	}
 
-	return &RepoAPIResponse, nil
+	w.Write([]byte(body))
 }
 
-func GetGithubURL(githubId int, userId int) (string, error) {
-	accessToken, accessTokenErr := auth.GetAccessToken(userId)
-	if accessTokenErr != nil || accessToken == nil {
-		return "", accessTokenErr
+// Webhook receives deliveries of the GitHub App, a push to a repository's default branch builds every project connected to it.
+// Deliveries are acknowledged with 202 whether or not they start a build, GitHub only needs to know it arrived
+func (gHandler GithubHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		utils.HandleError(utils.ErrInvalid, readErr, w, nil)
+		return
 	}
 
-	headers := map[string]string{
-		"Authorization": "Bearer " + *accessToken,
+	push, verifyErr := gitprovider.GitHub{}.VerifyWebhook(r, body, os.Getenv("GH_WEBHOOK_SECRET"))
+	if verifyErr == gitprovider.ErrUnverified {
+		utils.HandleError(utils.ErrUnAuthorized, nil, w, nil)
+		return
 	}
-
-	repoResp, repoErr := utils.Request("GET", fmt.Sprintf("https://api.github.com/repositories/%d", githubId), &headers, nil, nil)
-	if repoErr != nil {
-		return "", repoErr
+	if verifyErr != nil {
+		utils.HandleError(utils.ErrInvalid, verifyErr, w, nil)
+		return
 	}
 
-	defer repoResp.Body.Close()
-
-	repoBody, repoReadErr := io.ReadAll(repoResp.Body)
-	if repoReadErr != nil {
-		return "", repoErr
+	// other events, branch deletions and pushes to other branches have nothing to deploy
+	if push == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
 
-	type RepoResponse struct {
-		HtmlURL string `json:"html_url"`
+	buildIds, buildErr := build.PushBuild("github", push.RepoId, push.Sha)
+	if buildErr != nil {
+		utils.HandleError(utils.ErrInternal, buildErr, w, nil)
+		return
 	}
 
-	var Response RepoResponse
+	fmt.Printf("[GITHUB] push to repository %s queued builds %v\n", push.RepoId, buildIds)
 
-	deconstructorErr := json.Unmarshal(repoBody, &Response)
-	if deconstructorErr != nil {
-		return "", deconstructorErr
+	response, constructorErr := json.Marshal(map[string][]int{"build_ids": buildIds})
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
 	}
 
-	return Response.HtmlURL, nil
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(response)
 }
@@ -13,11 +13,13 @@ func GithubRouter() chi.Router {
 
 	gHandler := GithubHandler{}
 
+	// GitHub signs its deliveries, they carry no user token
+	r.Post("/webhook", gHandler.Webhook)
+
 	r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
 		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
-
-		r.Use(middleware.GithubTokenValidation)
+		r.Use(middleware.ActiveUser)
 
 		r.Get("/repos", gHandler.ListUserRepositories)
 	})
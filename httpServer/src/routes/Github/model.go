@@ -2,7 +2,9 @@ package github
 
 type GithubHandler struct{}
 
-type RepoAPIResponse []struct {
+type RepoAPIResponse []RepoAPIResponseItem
+
+type RepoAPIResponseItem struct {
 	ID       int    `json:"id"`
 	FullName string `json:"full_name"`
 	Owner    struct {
@@ -0,0 +1,467 @@
+package organization
+
+import (
+	"database/sql"
+	"encoding/json"
+	"httpServer/config"
+	"httpServer/src/access"
+	"httpServer/utils"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func (o OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	query := `
+		SELECT o.id, o.name, o.slug, m.role, o.created_at FROM "deploy-io".organizations o
+		JOIN "deploy-io".org_memberships m ON m.org_id = o.id
+		WHERE m.user_id = $1 ORDER BY o.name;
+	`
+	rows, queryErr := config.DataBase.Query(query, *userId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	organizations := []Organization{}
+
+	for rows.Next() {
+		var organization Organization
+		if err := rows.Scan(&organization.Id, &organization.Name, &organization.Slug, &organization.Role, &organization.CreatedAt); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		organizations = append(organizations, organization)
+	}
+
+	responseBody := map[string][]Organization{
+		"organizations": organizations,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// CreateOrganization creates an organization with the user as its first owner
+func (o OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody CreateOrganizationBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	requestBody.Name = strings.TrimSpace(requestBody.Name)
+
+	if len(requestBody.Name) == 0 || len(requestBody.Name) > 100 {
+		errMsg := "name must be between 1 and 100 characters"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	if !slugPattern.MatchString(requestBody.Slug) {
+		errMsg := "slug must be 1 to 63 lowercase letters, digits or dashes and can not start or end with a dash"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	tx, txErr := config.DataBase.Begin()
+	if txErr != nil {
+		utils.HandleError(utils.ErrInternal, txErr, w, nil)
+		return
+	}
+	defer tx.Rollback()
+
+	var orgId int
+
+	insertQuery := `INSERT INTO "deploy-io".organizations(name, slug) VALUES($1, $2) RETURNING id`
+	insertErr := tx.QueryRow(insertQuery, requestBody.Name, requestBody.Slug).Scan(&orgId)
+	if insertErr != nil {
+		if strings.Contains(insertErr.Error(), "duplicate key") {
+			utils.HandleError(utils.ErrAlreadyExists, insertErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, insertErr, w, nil)
+		return
+	}
+
+	memberQuery := `INSERT INTO "deploy-io".org_memberships(org_id, user_id, role) VALUES($1, $2, $3)`
+	if _, err := tx.Exec(memberQuery, orgId, *userId, access.RoleOwner); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		utils.HandleError(utils.ErrInternal, commitErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]int{
+		"org_id": orgId,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (o OrganizationHandler) Organization(w http.ResponseWriter, r *http.Request) {
+	orgId := chi.URLParam(r, "id")
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	var organization Organization
+
+	orgQuery := `
+		SELECT o.id, o.name, o.slug, m.role, o.created_at FROM "deploy-io".organizations o
+		JOIN "deploy-io".org_memberships m ON m.org_id = o.id AND m.user_id = $2
+		WHERE o.id = $1;
+	`
+	orgErr := config.DataBase.QueryRow(orgQuery, orgId, *userId).Scan(&organization.Id, &organization.Name, &organization.Slug, &organization.Role, &organization.CreatedAt)
+	if orgErr != nil {
+		utils.HandleError(utils.ErrInternal, orgErr, w, nil)
+		return
+	}
+
+	membersQuery := `
+		SELECT u.id, u.name, u.email, m.role, m.created_at FROM "deploy-io".org_memberships m
+		JOIN "deploy-io".users u ON u.id = m.user_id
+		WHERE m.org_id = $1 ORDER BY m.created_at;
+	`
+	rows, membersErr := config.DataBase.Query(membersQuery, orgId)
+	if membersErr != nil {
+		utils.HandleError(utils.ErrInternal, membersErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	members := []Member{}
+
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(&member.UserId, &member.Name, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		members = append(members, member)
+	}
+
+	responseBody := map[string]any{
+		"organization": organization,
+		"members":      members,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// DeleteOrganization removes an organization that no longer owns projects, they have to be transferred or deleted first
+func (o OrganizationHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	orgId := chi.URLParam(r, "id")
+
+	var projects int
+
+	countQuery := `SELECT COUNT(*) FROM "deploy-io".projects p WHERE p.org_id = $1`
+	if err := config.DataBase.QueryRow(countQuery, orgId).Scan(&projects); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	if projects > 0 {
+		errMsg := "the organization still owns projects"
+		utils.HandleError(utils.ErrAlreadyExists, nil, w, &errMsg)
+		return
+	}
+
+	deleteQuery := `DELETE FROM "deploy-io".organizations o WHERE o.id = $1`
+	if _, err := config.DataBase.Exec(deleteQuery, orgId); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (o OrganizationHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	orgId := chi.URLParam(r, "id")
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody AddMemberBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	role := access.Role(requestBody.Role)
+
+	if !role.Valid() {
+		errMsg := "role must be one of owner, admin, developer or viewer"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	// only owners can make someone else an owner
+	required := access.RoleAdmin
+	if role == access.RoleOwner {
+		required = access.RoleOwner
+	}
+
+	if !access.Org(w, orgId, *userId, required) {
+		return
+	}
+
+	var memberId int
+
+	userQuery := `SELECT u.id FROM "deploy-io".users u WHERE u.email = $1`
+	userErr := config.DataBase.QueryRow(userQuery, strings.TrimSpace(requestBody.Email)).Scan(&memberId)
+	if userErr != nil {
+		if userErr == sql.ErrNoRows {
+			errMsg := "no account uses this email, the user has to sign in once first"
+			utils.HandleError(utils.ErrNotFound, userErr, w, &errMsg)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, userErr, w, nil)
+		return
+	}
+
+	insertQuery := `INSERT INTO "deploy-io".org_memberships(org_id, user_id, role) VALUES($1, $2, $3)`
+	if _, err := config.DataBase.Exec(insertQuery, orgId, memberId, role); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			utils.HandleError(utils.ErrAlreadyExists, err, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	responseBody := map[string]int{
+		"user_id": memberId,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (o OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	orgId := chi.URLParam(r, "id")
+
+	memberId, convErr := strconv.Atoi(chi.URLParam(r, "userId"))
+	if convErr != nil {
+		utils.HandleError(utils.ErrInvalid, convErr, w, nil)
+		return
+	}
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateMemberBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	role := access.Role(requestBody.Role)
+
+	if !role.Valid() {
+		errMsg := "role must be one of owner, admin, developer or viewer"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	currentRole, roleErr := access.OrgRole(orgId, memberId)
+	if roleErr != nil {
+		utils.HandleError(utils.ErrInternal, roleErr, w, nil)
+		return
+	}
+
+	// owners are the only ones who can hand out or take away ownership
+	required := access.RoleAdmin
+	if role == access.RoleOwner || currentRole == access.RoleOwner {
+		required = access.RoleOwner
+	}
+
+	if !access.Org(w, orgId, *userId, required) {
+		return
+	}
+
+	if len(currentRole) == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	if currentRole == access.RoleOwner && role != access.RoleOwner {
+		if !keepsAnOwner(w, orgId) {
+			return
+		}
+	}
+
+	updateQuery := `UPDATE "deploy-io".org_memberships m SET role = $1 WHERE m.org_id = $2 AND m.user_id = $3`
+	if _, err := config.DataBase.Exec(updateQuery, role, orgId, memberId); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated member",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// RemoveMember takes a member out of the organization, every member can leave on their own
+func (o OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	orgId := chi.URLParam(r, "id")
+
+	memberId, convErr := strconv.Atoi(chi.URLParam(r, "userId"))
+	if convErr != nil {
+		utils.HandleError(utils.ErrInvalid, convErr, w, nil)
+		return
+	}
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	currentRole, roleErr := access.OrgRole(orgId, memberId)
+	if roleErr != nil {
+		utils.HandleError(utils.ErrInternal, roleErr, w, nil)
+		return
+	}
+
+	required := access.RoleAdmin
+	if memberId == *userId {
+		required = access.RoleViewer
+	} else if currentRole == access.RoleOwner {
+		required = access.RoleOwner
+	}
+
+	if !access.Org(w, orgId, *userId, required) {
+		return
+	}
+
+	if len(currentRole) == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	if currentRole == access.RoleOwner {
+		if !keepsAnOwner(w, orgId) {
+			return
+		}
+	}
+
+	deleteQuery := `DELETE FROM "deploy-io".org_memberships m WHERE m.org_id = $1 AND m.user_id = $2`
+	if _, err := config.DataBase.Exec(deleteQuery, orgId, memberId); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// keepsAnOwner makes sure another owner is left before one stops being an owner, writing the error response when not
+func keepsAnOwner(w http.ResponseWriter, orgId string) bool {
+	var owners int
+
+	query := `SELECT COUNT(*) FROM "deploy-io".org_memberships m WHERE m.org_id = $1 AND m.role = $2`
+	if err := config.DataBase.QueryRow(query, orgId, access.RoleOwner).Scan(&owners); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return false
+	}
+
+	if owners <= 1 {
+		errMsg := "an organization needs at least one owner"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+package organization
+
+import "time"
+
+type OrganizationHandler struct{}
+
+type Organization struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Member struct {
+	UserId    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateOrganizationBody struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// members are added by the email of their account, they need to have signed in once
+type AddMemberBody struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type UpdateMemberBody struct {
+	Role string `json:"role"`
+}
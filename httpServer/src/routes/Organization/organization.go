@@ -0,0 +1,36 @@
+package organization
+
+import (
+	"httpServer/src/access"
+	"httpServer/src/middleware"
+	auth "httpServer/src/routes/Auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func OrganizationRouter() chi.Router {
+	r := chi.NewRouter()
+
+	o := OrganizationHandler{}
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
+
+		r.Get("/all", o.ListOrganizations)
+		r.With(middleware.RequireScope(auth.ScopeAdmin)).Post("/new", o.CreateOrganization)
+		// changing members needs more than viewer in most cases, the handlers check the role each change needs
+		member := r.With(middleware.OrgParam("id", access.RoleViewer))
+
+		member.Get("/{id}", o.Organization)
+		r.With(middleware.OrgParam("id", access.RoleOwner)).Delete("/{id}", o.DeleteOrganization)
+		members := member.With(middleware.RequireScope(auth.ScopeAdmin))
+		members.Post("/{id}/members", o.AddMember)
+		members.Put("/{id}/members/{userId}", o.UpdateMember)
+		members.Delete("/{id}/members/{userId}", o.RemoveMember)
+	})
+
+	return r
+}
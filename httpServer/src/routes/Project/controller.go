@@ -4,37 +4,46 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"httpServer/config"
+	"httpServer/src/access"
+	"httpServer/src/gitprovider"
+	"httpServer/src/outbound"
+	auth "httpServer/src/routes/Auth"
+	build "httpServer/src/routes/Build"
 	deployment "httpServer/src/routes/Deployment"
-	github "httpServer/src/routes/Github"
 	"httpServer/utils"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func (p ProjectHandler) Project(w http.ResponseWriter, r *http.Request) {
 	projectId := chi.URLParam(r, "id")
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	query := `SELECT
 			name, directory, node_version,
 			install_command, build_command, output_folder,
-			github_id
-	FROM "deploy-io".projects p WHERE p.id = $1 AND p.user_id = $2`
+			provider, repo, repo_host, repo_token, spa_fallback, inject_runtime_env, user_id
+	FROM "deploy-io".projects p WHERE p.id = $1`
 
 	type ResponseBody struct {
 		Name           string `json:"name"`
@@ -43,19 +52,21 @@ func (p ProjectHandler) Project(w http.ResponseWriter, r *http.Request) {
 		InstallCommand string `json:"install_command"`
 		BuildCommand   string `json:"build_command"`
 		OutputFolder   string `json:"output_folder"`
-		GithubURL      string `json:"github_url"`
-	}
-
-	type tempBody struct {
-		GithubId int
+		SpaFallback    bool   `json:"spa_fallback"`
+		InjectEnv      bool   `json:"inject_runtime_env"`
+		Provider       string `json:"provider"`
+		RepoURL        string `json:"repo_url"`
+		GithubURL      string `json:"github_url,omitempty"`
 	}
 
 	var response ResponseBody
-	var temp tempBody
+	var repoHost, repoToken *string
+	// the repository is looked up with the access of the account that connected it
+	var repo gitprovider.Repo
 
-	err := config.DataBase.QueryRow(query, projectId, &userId).Scan(&response.Name,
+	err := config.DataBase.QueryRow(query, projectId).Scan(&response.Name,
 		&response.Directory, &response.NodeVersion, &response.InstallCommand,
-		&response.BuildCommand, &response.OutputFolder, &temp.GithubId)
+		&response.BuildCommand, &response.OutputFolder, &repo.Provider, &repo.Id, &repoHost, &repoToken, &response.SpaFallback, &response.InjectEnv, &repo.UserId)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
 			w.WriteHeader(404)
@@ -67,13 +78,37 @@ func (p ProjectHandler) Project(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	githubURL, fetchGHURLErr := github.GetGithubURL(temp.GithubId, *userId)
-	if fetchGHURLErr != nil {
-		utils.HandleError(utils.ErrInternal, fetchGHURLErr, w, nil)
+	if repoHost != nil {
+		repo.Host = *repoHost
+	}
+
+	if repoToken != nil {
+		token, decryptErr := gitprovider.Decrypt(*repoToken)
+		if decryptErr != nil {
+			utils.HandleError(utils.ErrInternal, decryptErr, w, nil)
+			return
+		}
+		repo.Token = token
+	}
+
+	provider, providerErr := gitprovider.For(repo.Provider)
+	if providerErr != nil {
+		utils.HandleError(utils.ErrInternal, providerErr, w, nil)
+		return
+	}
+
+	repoURL, fetchURLErr := provider.WebURL(repo)
+	if fetchURLErr != nil {
+		utils.HandleError(utils.ErrInternal, fetchURLErr, w, nil)
 		return
 	}
 
-	response.GithubURL = githubURL
+	response.Provider = repo.Provider
+	response.RepoURL = repoURL
+
+	if repo.Provider == "github" {
+		response.GithubURL = repoURL
+	}
 
 	responseBody, jsonConstructorErr := json.Marshal(response)
 	if jsonConstructorErr != nil {
@@ -85,32 +120,32 @@ func (p ProjectHandler) Project(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	projectId := chi.URLParam(r, "projectId")
 
 	var projectName string
+	var id int
 
-	query := `
-		DELETE FROM "deploy-io".projects p WHERE p.id = $1 AND p.user_id = $2
-		RETURNING p.name;
-	`
-	queryErr := config.DataBase.QueryRow(query, projectId, *userId).Scan(&projectName)
+	// files are looked up through the project's builds, so they go before the row does
+	query := `SELECT p.id, p.name FROM "deploy-io".projects p WHERE p.id = $1`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&id, &projectName)
 	if queryErr != nil {
 		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
 		return
 	}
 
-	err := deployment.DeleteFiles(projectName)
+	err := deployment.DeleteFiles(id, projectName)
 	if err != nil {
 		utils.HandleError(utils.ErrInternal, err, w, nil)
 		return
 	}
 
+	deleteQuery := `DELETE FROM "deploy-io".projects p WHERE p.id = $1`
+	_, deleteErr := config.DataBase.Exec(deleteQuery, id)
+	if deleteErr != nil {
+		utils.HandleError(utils.ErrInternal, deleteErr, w, nil)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -129,7 +164,7 @@ func (p ProjectHandler) InsertEnvironments(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	insertQuery := `INSERT INTO "deploy-io".environments(project_id, key, value) VALUES($1, $2, $3)`
+	insertQuery := `INSERT INTO "deploy-io".environments(project_id, key, value, is_public_runtime) VALUES($1, $2, $3, $4)`
 	insertStatement, preparationErr := config.DataBase.Prepare(insertQuery)
 	if preparationErr != nil {
 		utils.HandleError(utils.ErrInternal, preparationErr, w, nil)
@@ -146,7 +181,7 @@ func (p ProjectHandler) InsertEnvironments(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		_, insertErr := insertStatement.Exec(requestBody.ProjectId, environment.Key, val)
+		_, insertErr := insertStatement.Exec(requestBody.ProjectId, environment.Key, val, environment.IsPublicRuntime)
 		if insertErr != nil {
 			utils.HandleError(utils.ErrInternal, insertErr, w, nil)
 			return
@@ -170,19 +205,11 @@ func (p ProjectHandler) InsertEnvironments(w http.ResponseWriter, r *http.Reques
 func (p ProjectHandler) ListEnvKeys(w http.ResponseWriter, r *http.Request) {
 	projectId := chi.URLParam(r, "id")
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
-	query := `SELECT e.key, e.updated_at FROM "deploy-io".environments e
-		JOIN "deploy-io".projects p ON p.id = e.project_id
-		AND p.id = $1
-		AND p.user_id = $2
+	query := `SELECT e.key, e.is_public_runtime, e.updated_at FROM "deploy-io".environments e
+		WHERE e.project_id = $1
 		ORDER BY e.key;
 	`
-	rows, queryErr := config.DataBase.Query(query, projectId, userId)
+	rows, queryErr := config.DataBase.Query(query, projectId)
 	if queryErr != nil {
 		utils.HandleError(utils.ErrInvalid, queryErr, w, nil)
 		return
@@ -191,15 +218,16 @@ func (p ProjectHandler) ListEnvKeys(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	type Env struct {
-		Key       string    `json:"key"`
-		UpdatedAt time.Time `json:"updated_at"`
+		Key             string    `json:"key"`
+		IsPublicRuntime bool      `json:"is_public_runtime"`
+		UpdatedAt       time.Time `json:"updated_at"`
 	}
 
 	var envKeys []Env
 
 	for rows.Next() {
 		var env Env
-		rowsErr := rows.Scan(&env.Key, &env.UpdatedAt)
+		rowsErr := rows.Scan(&env.Key, &env.IsPublicRuntime, &env.UpdatedAt)
 		if rowsErr != nil {
 			utils.HandleError(utils.ErrInternal, rowsErr, w, nil)
 			return
@@ -236,24 +264,19 @@ func (p ProjectHandler) UpdateEnvValue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	encryptedValue, encErr := encrypt(requestBody.Value)
 	if encErr != nil {
 		utils.HandleError(utils.ErrInvalid, encErr, w, nil)
 		return
 	}
 
-	updateQuery := `UPDATE "deploy-io".environments SET value = $1 FROM "deploy-io".projects p
-		WHERE "deploy-io".environments.project_id = $2
-		AND "deploy-io".environments.key = $3
-		AND p.user_id = $4;
+	// is_public_runtime is kept when it is not sent
+	updateQuery := `UPDATE "deploy-io".environments e SET value = $1,
+		is_public_runtime = COALESCE($4, e.is_public_runtime)
+		WHERE e.project_id = $2
+		AND e.key = $3;
 	`
-	res, updateErr := config.DataBase.Exec(updateQuery, encryptedValue, requestBody.ProjectId, requestBody.Key, userId)
+	res, updateErr := config.DataBase.Exec(updateQuery, encryptedValue, requestBody.ProjectId, requestBody.Key, requestBody.IsPublicRuntime)
 	if updateErr != nil {
 		utils.HandleError(utils.ErrInternal, updateErr, w, nil)
 		return
@@ -266,7 +289,7 @@ func (p ProjectHandler) UpdateEnvValue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if rowsAffected == 0 {
-		utils.HandleError(utils.ErrNotFound, fmt.Errorf("either key doesn't exists or the user has no permission"), w, nil)
+		utils.HandleError(utils.ErrNotFound, fmt.Errorf("key doesn't exists"), w, nil)
 		return
 	}
 
@@ -291,12 +314,6 @@ func (p ProjectHandler) DeleteEnv(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := utils.GetUserIdFromContext(w, r)
-	if userId == nil {
-		utils.HandleError(utils.TokenExpired, nil, w, nil)
-		return
-	}
-
 	type RequestBody struct {
 		ProjectId int    `json:"project_id"`
 		EnvKey    string `json:"env_key"`
@@ -309,11 +326,8 @@ func (p ProjectHandler) DeleteEnv(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		DELETE FROM "deploy-io".environments e USING "deploy-io".projects p
-		WHERE e.project_id = $1 AND e.key = $2 AND p.id = $1 AND p.user_id = $3;
-	`
-	_, queryErr := config.DataBase.Exec(query, body.ProjectId, body.EnvKey, *userId)
+	query := `DELETE FROM "deploy-io".environments e WHERE e.project_id = $1 AND e.key = $2`
+	_, queryErr := config.DataBase.Exec(query, body.ProjectId, body.EnvKey)
 	if queryErr != nil {
 		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
 		return
@@ -331,12 +345,16 @@ func (p ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
 
 	var projects []ListProject
 
+	// personal projects of the user and every project of the organizations the user is a member of
 	query := `SELECT p.id, p.name, p.install_command,
 		p.build_command, p.output_folder, p.created_at,
-		p.directory, p.node_version, 
-		COALESCE (BOOL_OR(d.status), FALSE) AS is_active
+		p.directory, p.node_version, p.spa_fallback,
+		COALESCE (BOOL_OR(d.status), FALSE) AS is_active,
+		p.org_id, COALESCE(m.role, 'owner') AS role
 		FROM "deploy-io".projects p LEFT JOIN "deploy-io".deployments d ON d.project_id = p.id
-		WHERE p.user_id = $1 GROUP BY p.id;
+		LEFT JOIN "deploy-io".org_memberships m ON m.org_id = p.org_id AND m.user_id = $1
+		WHERE (p.org_id IS NULL AND p.user_id = $1) OR m.user_id IS NOT NULL
+		GROUP BY p.id, m.role;
 	`
 
 	rows, queryErr := config.DataBase.Query(query, *userId)
@@ -349,7 +367,7 @@ func (p ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		var project ListProject
-		rowsErr := rows.Scan(&project.Id, &project.Name, &project.InstallCommand, &project.BuildCommand, &project.OutputFolder, &project.CreatedAt, &project.Directory, &project.NodeVersion, &project.IsActive)
+		rowsErr := rows.Scan(&project.Id, &project.Name, &project.InstallCommand, &project.BuildCommand, &project.OutputFolder, &project.CreatedAt, &project.Directory, &project.NodeVersion, &project.SpaFallback, &project.IsActive, &project.OrgId, &project.Role)
 		if rowsErr != nil {
 			utils.HandleError(utils.ErrInternal, rowsErr, w, nil)
 			return
@@ -391,6 +409,24 @@ func (p ProjectHandler) CreateNewProject(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if nameErr := validateProjectName(project.Name); nameErr != nil {
+		errMsg := nameErr.Error()
+		utils.HandleError(utils.ErrInvalid, nameErr, w, &errMsg)
+		return
+	}
+
+	// the old subdomain of a renamed project is still redirecting
+	aliasTaken, aliasErr := isAliasTaken(project.Name, 0)
+	if aliasErr != nil {
+		utils.HandleError(utils.ErrInternal, aliasErr, w, nil)
+		return
+	}
+
+	if aliasTaken {
+		utils.HandleError(utils.ErrAlreadyExists, nil, w, nil)
+		return
+	}
+
 	installCommand, buildCommand, outputFolder, nodeVersion, directory := getDefaults()
 
 	if project.InstallCommand == nil {
@@ -413,15 +449,83 @@ func (p ProjectHandler) CreateNewProject(w http.ResponseWriter, r *http.Request)
 		project.Directory = &directory
 	}
 
+	if validationErr := validateSettings(*project.InstallCommand, *project.BuildCommand, *project.OutputFolder, *project.NodeVersion, *project.Directory); validationErr != nil {
+		errMsg := validationErr.Error()
+		utils.HandleError(utils.ErrInvalid, validationErr, w, &errMsg)
+		return
+	}
+
+	spaFallback := true
+	if project.SpaFallback != nil {
+		spaFallback = *project.SpaFallback
+	}
+
 	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
 
-	githubId, convErr := strconv.Atoi(project.GithubId)
-	if convErr != nil {
-		utils.HandleError(utils.ErrInvalid, convErr, w, nil)
+	if project.OrgId != nil && !access.Org(w, *project.OrgId, *userId, access.RoleAdmin) {
+		return
+	}
+
+	repo, repoErr := projectRepo(project, *userId)
+	if repoErr != nil {
+		errMsg := repoErr.Error()
+		utils.HandleError(utils.ErrInvalid, repoErr, w, &errMsg)
+		return
+	}
+
+	provider, providerErr := gitprovider.For(repo.Provider)
+	if providerErr != nil {
+		errMsg := providerErr.Error()
+		utils.HandleError(utils.ErrInvalid, providerErr, w, &errMsg)
+		return
+	}
+
+	// the repository has to be readable with the given access before a project builds from it
+	_, resolveErr := provider.ResolveRef(repo, "")
+	if resolveErr == auth.ErrGithubTokenExpired {
+		utils.HandleError(utils.TokenExpired, resolveErr, w, nil)
+		return
+	}
+	if resolveErr != nil {
+		errMsg := "[PROJECT] repository could not be read"
+		utils.HandleError(utils.ErrInvalid, resolveErr, w, &errMsg)
 		return
 	}
 
-	projectId, dbErr := insertProjectIntoDB(*userId, project.Name, githubId, *project.InstallCommand, *project.BuildCommand, removeLeadingAndTrailingSlashes(*project.OutputFolder), *project.NodeVersion, removeLeadingAndTrailingSlashes(*project.Directory))
+	var repoToken, webhookSecret *string
+
+	if len(repo.Token) > 0 {
+		encryptedToken, encErr := encrypt(repo.Token)
+		if encErr != nil {
+			utils.HandleError(utils.ErrInternal, encErr, w, nil)
+			return
+		}
+		repoToken = &encryptedToken
+	}
+
+	// GitLab and Gitea send their webhooks to the project, GitHub's go to the GitHub App
+	secret := ""
+	if repo.Provider == "gitlab" || repo.Provider == "gitea" {
+		secretBytes := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, secretBytes); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+		secret = hex.EncodeToString(secretBytes)
+
+		encryptedSecret, encErr := encrypt(secret)
+		if encErr != nil {
+			utils.HandleError(utils.ErrInternal, encErr, w, nil)
+			return
+		}
+		webhookSecret = &encryptedSecret
+	}
+
+	projectId, dbErr := insertProjectIntoDB(*userId, project.OrgId, project.Name, repo, repoToken, webhookSecret, *project.InstallCommand, *project.BuildCommand, removeLeadingAndTrailingSlashes(*project.OutputFolder), *project.NodeVersion, removeLeadingAndTrailingSlashes(*project.Directory), spaFallback)
 	if dbErr != nil {
 
 		if strings.Contains(dbErr.Error(), "duplicate key") {
@@ -433,10 +537,16 @@ func (p ProjectHandler) CreateNewProject(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	responseBody := map[string]int{
+	responseBody := map[string]any{
 		"project_id": int(*projectId),
 	}
 
+	// the secret is only ever shown here, it goes into the webhook settings of the repository
+	if len(secret) > 0 {
+		responseBody["webhook_path"] = fmt.Sprintf("/api/v1/git/webhook/%d", *projectId)
+		responseBody["webhook_secret"] = secret
+	}
+
 	response, constructorErr := json.Marshal(responseBody)
 	if constructorErr != nil {
 		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
@@ -446,57 +556,1937 @@ func (p ProjectHandler) CreateNewProject(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte(response))
 }
 
-func insertProjectIntoDB(userId int, name string, githubId int, installCommand string, buildCommand string, outputFolder string, nodeVersion string, directory string) (*int, error) {
-	var projectId int
-	query := "INSERT INTO \"deploy-io\".projects (user_id, name, github_id, install_command, build_command, output_folder, node_version, directory) VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id"
-	err := config.DataBase.QueryRow(query, userId, name, githubId, installCommand, buildCommand, outputFolder, nodeVersion, directory).Scan(&projectId)
-	if err != nil {
-		return nil, err
+func (p ProjectHandler) Protection(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	var mode string
+	var hasPassword bool
+
+	query := `SELECT p.access_protection, p.access_password_hash IS NOT NULL FROM "deploy-io".projects p WHERE p.id = $1`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&mode, &hasPassword)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, queryErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
 	}
 
-	return &projectId, nil
+	tokensQuery := `SELECT t.id, t.name, t.expires_at, t.created_at FROM "deploy-io".bypass_tokens t WHERE t.project_id = $1 ORDER BY t.id`
+	rows, tokensErr := config.DataBase.Query(tokensQuery, projectId)
+	if tokensErr != nil {
+		utils.HandleError(utils.ErrInternal, tokensErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	tokens := []BypassToken{}
+
+	for rows.Next() {
+		var token BypassToken
+		rowsErr := rows.Scan(&token.Id, &token.Name, &token.ExpiresAt, &token.CreatedAt)
+		if rowsErr != nil {
+			utils.HandleError(utils.ErrInternal, rowsErr, w, nil)
+			return
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	responseBody := map[string]any{
+		"mode":          mode,
+		"has_password":  hasPassword,
+		"bypass_tokens": tokens,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
 }
 
-func getDefaults() (string, string, string, string, string) {
-	var buildCommand, outputFolder string
+func (p ProjectHandler) UpdateProtection(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
 
-	installCommand, installCommandExists := os.LookupEnv("DEFAULT_INSTALL_COMMAND")
-	buildCommand, buildCommandExists := os.LookupEnv("DEFAULT_BUILD_COMMAND")
-	outputFolder, outputFolderExists := os.LookupEnv("DEFAULT_BUILD_DIRECTORY")
-	directory, directoryExists := os.LookupEnv("DEFAULT_PROJECT_DIRECTORY")
-	nodeVersion, nodeVersionExists := os.LookupEnv("DEFAULT_NODE_VERSION")
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
 
-	if !buildCommandExists || !outputFolderExists || !installCommandExists || !nodeVersionExists || !directoryExists {
-		return "npm install", "npm run build", "./dist", "20", "./"
+	var requestBody UpdateProtectionBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
 	}
 
-	return installCommand, buildCommand, outputFolder, nodeVersion, directory
+	if requestBody.Mode != "none" && requestBody.Mode != "basic" && requestBody.Mode != "login" {
+		errMsg := "mode must be one of none, basic or login"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	// a nil hash keeps the stored password, so the mode can be switched without re-entering it
+	var passwordHash *string
+
+	if requestBody.Password != nil {
+		if len(*requestBody.Password) < 8 || len(*requestBody.Password) > 72 {
+			errMsg := "password must be between 8 and 72 characters"
+			utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+			return
+		}
+
+		hash, hashErr := bcrypt.GenerateFromPassword([]byte(*requestBody.Password), bcrypt.DefaultCost)
+		if hashErr != nil {
+			utils.HandleError(utils.ErrInternal, hashErr, w, nil)
+			return
+		}
+
+		hashString := string(hash)
+		passwordHash = &hashString
+	}
+
+	var hasPassword bool
+
+	lookupQuery := `SELECT p.access_password_hash IS NOT NULL FROM "deploy-io".projects p WHERE p.id = $1`
+	lookupErr := config.DataBase.QueryRow(lookupQuery, projectId).Scan(&hasPassword)
+	if lookupErr != nil {
+		if lookupErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, lookupErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, lookupErr, w, nil)
+		return
+	}
+
+	if requestBody.Mode != "none" && !hasPassword && passwordHash == nil {
+		errMsg := "a password is required to protect the site"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	updateQuery := `
+		UPDATE "deploy-io".projects p SET access_protection = $1, access_password_hash = COALESCE($2, p.access_password_hash)
+		WHERE p.id = $3;
+	`
+	_, updateErr := config.DataBase.Exec(updateQuery, requestBody.Mode, passwordHash, projectId)
+	if updateErr != nil {
+		utils.HandleError(utils.ErrInternal, updateErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated access protection",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
 }
 
-func encrypt(text string) (string, error) {
-	key, keyExists := os.LookupEnv("ENV_SECRET")
-	if !keyExists {
-		return "", fmt.Errorf("[ENC] env secret is not accessible")
+func (p ProjectHandler) CreateBypassToken(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
 	}
 
-	keyBytes := []byte(key)
+	var requestBody CreateBypassTokenBody
 
-	block, err := aes.NewCipher(keyBytes)
-	if err != nil {
-		return "", err
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
 	}
 
-	cipherText := make([]byte, aes.BlockSize+len(text))
-	iv := cipherText[:aes.BlockSize]
+	if len(strings.TrimSpace(requestBody.Name)) == 0 || requestBody.ExpiresInDays < 0 {
+		utils.HandleError(utils.ErrInvalid, nil, w, nil)
+		return
+	}
 
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", err
+	tokenBytes := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, tokenBytes); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(cipherText[aes.BlockSize:], []byte(text))
+	token := hex.EncodeToString(tokenBytes)
+	tokenHash := sha256.Sum256([]byte(token))
 
-	return hex.EncodeToString(cipherText), nil
+	var expiresAt *time.Time
+	if requestBody.ExpiresInDays > 0 {
+		expiry := time.Now().AddDate(0, 0, requestBody.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	var tokenId int
+
+	query := `
+		INSERT INTO "deploy-io".bypass_tokens (project_id, name, token_hash, expires_at)
+		SELECT p.id, $1, $2, $3 FROM "deploy-io".projects p WHERE p.id = $4
+		RETURNING id;
+	`
+	queryErr := config.DataBase.QueryRow(query, requestBody.Name, hex.EncodeToString(tokenHash[:]), expiresAt, projectId).Scan(&tokenId)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, queryErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	// the token is only ever shown here, the database keeps its hash
+	responseBody := map[string]any{
+		"id":    tokenId,
+		"token": token,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (p ProjectHandler) DeleteBypassToken(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+	tokenId := chi.URLParam(r, "tokenId")
+
+	query := `DELETE FROM "deploy-io".bypass_tokens t WHERE t.id = $1 AND t.project_id = $2`
+	res, queryErr := config.DataBase.Exec(query, tokenId, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p ProjectHandler) Canary(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	var buildId *int
+	var weight int
+
+	query := `SELECT p.canary_build_id, p.canary_weight FROM "deploy-io".projects p WHERE p.id = $1`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&buildId, &weight)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, queryErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]any{
+		"build_id": buildId,
+		"weight":   weight,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// UpdateCanary sets the percentage of visitors sent to the candidate, it is kept for the next canary build
+func (p ProjectHandler) UpdateCanary(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateCanaryBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	if requestBody.Weight < 0 || requestBody.Weight > 100 {
+		errMsg := "weight must be between 0 and 100"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	query := `UPDATE "deploy-io".projects p SET canary_weight = $1 WHERE p.id = $2`
+	res, queryErr := config.DataBase.Exec(query, requestBody.Weight, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated canary weight",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// PromoteCanary makes the candidate the active deployment for every visitor
+func (p ProjectHandler) PromoteCanary(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	var id int
+	var buildId *int
+
+	query := `SELECT p.id, p.canary_build_id FROM "deploy-io".projects p WHERE p.id = $1`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&id, &buildId)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, queryErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	if buildId == nil {
+		errMsg := "the project has no canary to promote"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	// visitors must never see the site without an active deployment, so the switch happens at once
+	tx, txErr := config.DataBase.Begin()
+	if txErr != nil {
+		utils.HandleError(utils.ErrInternal, txErr, w, nil)
+		return
+	}
+
+	defer tx.Rollback()
+
+	deactivateQuery := `UPDATE "deploy-io".deployments SET status = false WHERE project_id = $1 AND status = true`
+	if _, err := tx.Exec(deactivateQuery, id); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	activateQuery := `
+		UPDATE "deploy-io".deployments SET status = true WHERE id = (
+			SELECT d.id FROM "deploy-io".deployments d WHERE d.project_id = $1 AND d.build_id = $2 ORDER BY d.created_at DESC LIMIT 1
+		);
+	`
+	res, activateErr := tx.Exec(activateQuery, id, *buildId)
+	if activateErr != nil {
+		utils.HandleError(utils.ErrInternal, activateErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	// the canary's deployment is gone, returning here rolls back the deactivation above
+	if rowsAffected != 1 {
+		errMsg := "the canary build has no deployment to promote"
+		utils.HandleError(utils.ErrNotFound, nil, w, &errMsg)
+		return
+	}
+
+	clearQuery := `UPDATE "deploy-io".projects SET canary_build_id = NULL WHERE id = $1`
+	if _, err := tx.Exec(clearQuery, id); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		utils.HandleError(utils.ErrInternal, commitErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]any{
+		"msg":      "Promoted canary",
+		"build_id": *buildId,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// DeleteCanary sends every visitor back to the active deployment
+func (p ProjectHandler) DeleteCanary(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	query := `UPDATE "deploy-io".projects p SET canary_build_id = NULL WHERE p.id = $1`
+	res, queryErr := config.DataBase.Exec(query, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UpdateRuntimeEnv turns the window.__ENV snippet in served html on or off
+func (p ProjectHandler) UpdateRuntimeEnv(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateRuntimeEnvBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	query := `UPDATE "deploy-io".projects p SET inject_runtime_env = $1 WHERE p.id = $2`
+	res, queryErr := config.DataBase.Exec(query, requestBody.Enabled, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated runtime environment injection",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (p ProjectHandler) Limits(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	var limits UpdateLimitsBody
+	var bandwidthUsed int64
+
+	query := `
+		SELECT p.rate_limit, p.rate_burst, p.bandwidth_quota, p.quota_page, COALESCE(u.bytes, 0)
+		FROM "deploy-io".projects p
+		LEFT JOIN "deploy-io".site_bandwidth_usage u ON u.project_id = p.id AND u.month = date_trunc('month', NOW() AT TIME ZONE 'UTC')::date
+		WHERE p.id = $1;
+	`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&limits.RateLimit, &limits.RateBurst, &limits.BandwidthQuota, &limits.QuotaPage, &bandwidthUsed)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, queryErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]any{
+		"rate_limit":      limits.RateLimit,
+		"rate_burst":      limits.RateBurst,
+		"bandwidth_quota": limits.BandwidthQuota,
+		"quota_page":      limits.QuotaPage,
+		"bandwidth_used":  bandwidthUsed,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (p ProjectHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateLimitsBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	if requestBody.RateLimit != nil && (*requestBody.RateLimit < 1 || *requestBody.RateLimit > 10000) {
+		errMsg := "rate_limit must be between 1 and 10000 requests per second"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	if requestBody.RateBurst != nil && (*requestBody.RateBurst < 1 || *requestBody.RateBurst > 100000) {
+		errMsg := "rate_burst must be between 1 and 100000 requests"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	if requestBody.BandwidthQuota != nil && *requestBody.BandwidthQuota < 1 {
+		errMsg := "bandwidth_quota must be a positive number of bytes"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	if requestBody.QuotaPage != nil && len(*requestBody.QuotaPage) > 64*1024 {
+		errMsg := "quota_page can be at most 64KB"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	query := `
+		UPDATE "deploy-io".projects p SET rate_limit = $1, rate_burst = $2, bandwidth_quota = $3, quota_page = $4
+		WHERE p.id = $5;
+	`
+	res, queryErr := config.DataBase.Exec(query, requestBody.RateLimit, requestBody.RateBurst, requestBody.BandwidthQuota, requestBody.QuotaPage, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated limits",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// UpdateProject changes the build settings of a project, the previous values are kept in its history
+func (p ProjectHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	projectId, convErr := strconv.Atoi(chi.URLParam(r, "id"))
+	if convErr != nil {
+		utils.HandleError(utils.ErrInvalid, convErr, w, nil)
+		return
+	}
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateProjectBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	tx, txErr := config.DataBase.Begin()
+	if txErr != nil {
+		utils.HandleError(utils.ErrInternal, txErr, w, nil)
+		return
+	}
+	defer tx.Rollback()
+
+	var installCommand, buildCommand, outputFolder, nodeVersion, directory string
+	var spaFallback bool
+
+	selectQuery := `
+		SELECT p.install_command, p.build_command, p.output_folder, p.node_version, p.directory, p.spa_fallback
+		FROM "deploy-io".projects p WHERE p.id = $1 FOR UPDATE;
+	`
+	selectErr := tx.QueryRow(selectQuery, projectId).Scan(&installCommand, &buildCommand, &outputFolder, &nodeVersion, &directory, &spaFallback)
+	if selectErr != nil {
+		if selectErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, selectErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, selectErr, w, nil)
+		return
+	}
+
+	changes := map[string]SettingChange{}
+
+	// only the values that change are validated, projects created before the validation existed
+	// may hold settings it refuses and should stay editable
+	var validationErr error
+
+	changeSetting := func(name string, current *string, requested *string) {
+		if requested == nil || validationErr != nil {
+			return
+		}
+
+		value := strings.TrimSpace(*requested)
+		if name == "output_folder" || name == "directory" {
+			value = removeLeadingAndTrailingSlashes(value)
+		}
+
+		if value == *current {
+			return
+		}
+
+		if validationErr = validateSetting(name, value); validationErr != nil {
+			return
+		}
+
+		changes[name] = SettingChange{From: *current, To: value}
+		*current = value
+	}
+
+	changeSetting("install_command", &installCommand, requestBody.InstallCommand)
+	changeSetting("build_command", &buildCommand, requestBody.BuildCommand)
+	changeSetting("output_folder", &outputFolder, requestBody.OutputFolder)
+	changeSetting("node_version", &nodeVersion, requestBody.NodeVersion)
+	changeSetting("directory", &directory, requestBody.Directory)
+
+	if requestBody.SpaFallback != nil && *requestBody.SpaFallback != spaFallback {
+		changes["spa_fallback"] = SettingChange{From: spaFallback, To: *requestBody.SpaFallback}
+		spaFallback = *requestBody.SpaFallback
+	}
+
+	if validationErr != nil {
+		errMsg := validationErr.Error()
+		utils.HandleError(utils.ErrInvalid, validationErr, w, &errMsg)
+		return
+	}
+
+	if len(changes) == 0 {
+		responseBody := map[string]any{
+			"msg":     "Nothing to update",
+			"changes": changes,
+		}
+
+		response, constructorErr := json.Marshal(responseBody)
+		if constructorErr != nil {
+			utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+			return
+		}
+
+		w.Write(response)
+		return
+	}
+
+	updateQuery := `
+		UPDATE "deploy-io".projects SET install_command = $1, build_command = $2, output_folder = $3,
+		node_version = $4, directory = $5, spa_fallback = $6
+		WHERE id = $7;
+	`
+	_, updateErr := tx.Exec(updateQuery, installCommand, buildCommand, outputFolder, nodeVersion, directory, spaFallback, projectId)
+	if updateErr != nil {
+		utils.HandleError(utils.ErrInternal, updateErr, w, nil)
+		return
+	}
+
+	changesJSON, marshalErr := json.Marshal(changes)
+	if marshalErr != nil {
+		utils.HandleError(utils.ErrInternal, marshalErr, w, nil)
+		return
+	}
+
+	var historyId int
+
+	historyQuery := `INSERT INTO "deploy-io".project_history(project_id, user_id, changes) VALUES($1, $2, $3) RETURNING id`
+	historyErr := tx.QueryRow(historyQuery, projectId, *userId, changesJSON).Scan(&historyId)
+	if historyErr != nil {
+		utils.HandleError(utils.ErrInternal, historyErr, w, nil)
+		return
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		utils.HandleError(utils.ErrInternal, commitErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]any{
+		"msg":     "Updated project",
+		"changes": changes,
+	}
+
+	// the settings are saved either way, a failed rebuild is reported so it can be retried from the builds page
+	if requestBody.Rebuild {
+		buildId, rebuildErr := build.Rebuild(projectId)
+		if rebuildErr != nil {
+			log.Println("[PROJECT] could not queue a rebuild: " + rebuildErr.Error())
+			responseBody["rebuild_error"] = "could not queue a rebuild"
+		} else {
+			responseBody["build_id"] = *buildId
+
+			linkQuery := `UPDATE "deploy-io".project_history SET build_id = $1 WHERE id = $2`
+			if _, linkErr := config.DataBase.Exec(linkQuery, *buildId, historyId); linkErr != nil {
+				log.Println("[PROJECT] could not link the rebuild to the history: " + linkErr.Error())
+			}
+		}
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// RenameProject changes the subdomain of a project. Files of deployments made before every build got
+// its own prefix are stored under the name and are moved, the old name redirects for RENAME_REDIRECT_DAYS.
+func (p ProjectHandler) RenameProject(w http.ResponseWriter, r *http.Request) {
+	projectId, convErr := strconv.Atoi(chi.URLParam(r, "id"))
+	if convErr != nil {
+		utils.HandleError(utils.ErrInvalid, convErr, w, nil)
+		return
+	}
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody RenameProjectBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	newName := strings.TrimSpace(requestBody.Name)
+
+	if nameErr := validateProjectName(newName); nameErr != nil {
+		errMsg := nameErr.Error()
+		utils.HandleError(utils.ErrInvalid, nameErr, w, &errMsg)
+		return
+	}
+
+	var oldName string
+
+	nameQuery := `SELECT p.name FROM "deploy-io".projects p WHERE p.id = $1`
+	nameErr := config.DataBase.QueryRow(nameQuery, projectId).Scan(&oldName)
+	if nameErr != nil {
+		if nameErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, nameErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, nameErr, w, nil)
+		return
+	}
+
+	if newName == oldName {
+		errMsg := "the project already has this name"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	aliasTaken, aliasErr := isAliasTaken(newName, projectId)
+	if aliasErr != nil {
+		utils.HandleError(utils.ErrInternal, aliasErr, w, nil)
+		return
+	}
+
+	var nameTaken bool
+
+	nameTakenQuery := `SELECT EXISTS (SELECT 1 FROM "deploy-io".projects p WHERE p.name = $1)`
+	if err := config.DataBase.QueryRow(nameTakenQuery, newName).Scan(&nameTaken); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	if aliasTaken || nameTaken {
+		utils.HandleError(utils.ErrAlreadyExists, nil, w, nil)
+		return
+	}
+
+	// files are copied before the transaction so the project row is only locked for the updates below, the
+	// site keeps being served from the old prefix until the commit. Unless the rename goes through, exactly
+	// the objects this call copied are removed again, never what was stored under the name before
+	copied, copyErr := deployment.CopyPrefix(oldName+"/", newName+"/")
+
+	committed := false
+	defer func() {
+		if !committed {
+			removeCopies(copied)
+		}
+	}()
+
+	if copyErr != nil {
+		utils.HandleError(utils.ErrInternal, copyErr, w, nil)
+		return
+	}
+
+	tx, txErr := config.DataBase.Begin()
+	if txErr != nil {
+		utils.HandleError(utils.ErrInternal, txErr, w, nil)
+		return
+	}
+	defer tx.Rollback()
+
+	var lockedName string
+
+	lockQuery := `SELECT p.name FROM "deploy-io".projects p WHERE p.id = $1 FOR UPDATE`
+	lockErr := tx.QueryRow(lockQuery, projectId).Scan(&lockedName)
+	if lockErr != nil {
+		if lockErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, lockErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, lockErr, w, nil)
+		return
+	}
+
+	// another rename finished while the files were copied, the copies are of a name the project no longer has
+	if lockedName != oldName {
+		errMsg := "the project was renamed in the meantime"
+		utils.HandleError(utils.ErrAlreadyExists, nil, w, &errMsg)
+		return
+	}
+
+	// the unique index still decides when another project took the name since it was checked
+	updateQuery := `UPDATE "deploy-io".projects SET name = $1 WHERE id = $2`
+	_, updateErr := tx.Exec(updateQuery, newName, projectId)
+	if updateErr != nil {
+		if strings.Contains(updateErr.Error(), "duplicate key") {
+			utils.HandleError(utils.ErrAlreadyExists, updateErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, updateErr, w, nil)
+		return
+	}
+
+	// renaming back to a previous name takes it out of the aliases
+	aliasQuery := `DELETE FROM "deploy-io".project_aliases WHERE name = $1 OR expires_at < NOW()`
+	if _, err := tx.Exec(aliasQuery, newName); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(getRenameRedirectDays()) * 24 * time.Hour)
+
+	insertAliasQuery := `
+		INSERT INTO "deploy-io".project_aliases(name, project_id, expires_at) VALUES($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET project_id = EXCLUDED.project_id, expires_at = EXCLUDED.expires_at;
+	`
+	if _, err := tx.Exec(insertAliasQuery, oldName, projectId, expiresAt); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	changes, marshalErr := json.Marshal(map[string]SettingChange{"name": {From: oldName, To: newName}})
+	if marshalErr != nil {
+		utils.HandleError(utils.ErrInternal, marshalErr, w, nil)
+		return
+	}
+
+	historyQuery := `INSERT INTO "deploy-io".project_history(project_id, user_id, changes) VALUES($1, $2, $3)`
+	if _, err := tx.Exec(historyQuery, projectId, *userId, changes); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		utils.HandleError(utils.ErrInternal, commitErr, w, nil)
+		return
+	}
+	committed = true
+
+	// the copies are in use now, leftovers under the old name are only wasted space
+	if deleteErr := deployment.DeletePrefix(oldName + "/"); deleteErr != nil {
+		log.Println("[PROJECT] could not delete files under the old name " + oldName + ": " + deleteErr.Error())
+	}
+
+	responseBody := map[string]any{
+		"msg":                 "Renamed project",
+		"name":                newName,
+		"redirect_expires_at": expiresAt,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// removeCopies undoes the copies of a rename that did not go through
+func removeCopies(keys []string) {
+	if err := deployment.DeleteObjects(keys); err != nil {
+		log.Println("[PROJECT] could not remove the copies of a failed rename: " + err.Error())
+	}
+}
+
+// TransferProject moves a project into an organization or back to the personal account of the user.
+// Only the owner can give a project away and the user has to be an admin of the organization receiving it.
+func (p ProjectHandler) TransferProject(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		utils.HandleError(utils.TokenExpired, nil, w, nil)
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody TransferProjectBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	if requestBody.OrgId != nil && !access.Org(w, *requestBody.OrgId, *userId, access.RoleAdmin) {
+		return
+	}
+
+	// the user transferring becomes the account whose GitHub access builds the project
+	query := `UPDATE "deploy-io".projects p SET org_id = $1, user_id = $2 WHERE p.id = $3`
+	res, queryErr := config.DataBase.Exec(query, requestBody.OrgId, *userId, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Transferred project",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// ProjectHistory pages through the setting changes of the project, newest first
+func (p ProjectHandler) ProjectHistory(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("l"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("o"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := `
+		SELECT h.id, h.user_id, h.changes, h.build_id, h.created_at FROM "deploy-io".project_history h
+		WHERE h.project_id = $1
+		ORDER BY h.created_at DESC, h.id DESC LIMIT $2 OFFSET $3;
+	`
+	rows, queryErr := config.DataBase.Query(query, projectId, limit, offset)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+	defer rows.Close()
+
+	history := []HistoryEntry{}
+
+	for rows.Next() {
+		var entry HistoryEntry
+		var changes []byte
+
+		if err := rows.Scan(&entry.Id, &entry.UserId, &changes, &entry.BuildId, &entry.CreatedAt); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		history = append(history, entry)
+	}
+
+	responseBody := map[string]any{
+		"history": history,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (p ProjectHandler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	var maintenance UpdateMaintenanceBody
+
+	query := `
+		SELECT p.maintenance_enabled, p.maintenance_starts_at, p.maintenance_ends_at, p.maintenance_page, p.maintenance_allowlist
+		FROM "deploy-io".projects p WHERE p.id = $1;
+	`
+	queryErr := config.DataBase.QueryRow(query, projectId).Scan(&maintenance.Enabled, &maintenance.StartsAt,
+		&maintenance.EndsAt, &maintenance.Page, pq.Array(&maintenance.Allowlist))
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			utils.HandleError(utils.ErrNotFound, queryErr, w, nil)
+			return
+		}
+
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	now := time.Now()
+	active := maintenance.Enabled &&
+		(maintenance.StartsAt == nil || !now.Before(*maintenance.StartsAt)) &&
+		(maintenance.EndsAt == nil || now.Before(*maintenance.EndsAt))
+
+	responseBody := map[string]any{
+		"enabled":   maintenance.Enabled,
+		"starts_at": maintenance.StartsAt,
+		"ends_at":   maintenance.EndsAt,
+		"page":      maintenance.Page,
+		"allowlist": maintenance.Allowlist,
+		"active":    active,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (p ProjectHandler) UpdateMaintenance(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateMaintenanceBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	if requestBody.StartsAt != nil && requestBody.EndsAt != nil && !requestBody.EndsAt.After(*requestBody.StartsAt) {
+		errMsg := "ends_at must be after starts_at"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	if requestBody.Page != nil && len(*requestBody.Page) > 64*1024 {
+		errMsg := "page can be at most 64KB"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	if len(requestBody.Allowlist) > 50 {
+		errMsg := "allowlist can have at most 50 entries"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	// plain addresses are stored as single host networks so the static server only has to deal with CIDRs
+	allowlist := []string{}
+	for _, entry := range requestBody.Allowlist {
+		network, parseErr := allowlistNetwork(strings.TrimSpace(entry))
+		if parseErr != nil {
+			errMsg := fmt.Sprintf("%q is not an IP address or CIDR range", entry)
+			utils.HandleError(utils.ErrInvalid, parseErr, w, &errMsg)
+			return
+		}
+
+		allowlist = append(allowlist, network)
+	}
+
+	query := `
+		UPDATE "deploy-io".projects p SET maintenance_enabled = $1, maintenance_starts_at = $2, maintenance_ends_at = $3,
+		maintenance_page = $4, maintenance_allowlist = $5
+		WHERE p.id = $6;
+	`
+	res, queryErr := config.DataBase.Exec(query, requestBody.Enabled, requestBody.StartsAt, requestBody.EndsAt,
+		requestBody.Page, pq.Array(allowlist), projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated maintenance",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func allowlistNetwork(entry string) (string, error) {
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return "", err
+		}
+
+		return network.String(), nil
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return "", fmt.Errorf("invalid ip address")
+	}
+
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+
+	return ip.String() + "/128", nil
+}
+
+// ListFormSubmissions pages through the submissions of the project, newest first, `form` filters by form name
+func (p ProjectHandler) ListFormSubmissions(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("l"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	pageNumber, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+
+	formName := r.URL.Query().Get("form")
+
+	query := `
+		SELECT s.id, s.form_name, s.path, s.data, s.user_agent, s.created_at
+		FROM "deploy-io".form_submissions s
+		WHERE s.project_id = $1 AND ($2 = '' OR s.form_name = $2)
+		ORDER BY s.id DESC LIMIT $3 OFFSET $4;
+	`
+	rows, queryErr := config.DataBase.Query(query, projectId, formName, limit, (pageNumber-1)*limit)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	submissions, scanErr := scanFormSubmissions(rows)
+	if scanErr != nil {
+		utils.HandleError(utils.ErrInternal, scanErr, w, nil)
+		return
+	}
+
+	responseBody := map[string]any{
+		"submissions": submissions,
+		"currentPage": pageNumber,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// ExportFormSubmissions writes every submission as csv, one column per field seen in any submission
+func (p ProjectHandler) ExportFormSubmissions(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	formName := r.URL.Query().Get("form")
+
+	query := `
+		SELECT s.id, s.form_name, s.path, s.data, s.user_agent, s.created_at
+		FROM "deploy-io".form_submissions s
+		WHERE s.project_id = $1 AND ($2 = '' OR s.form_name = $2)
+		ORDER BY s.id;
+	`
+	rows, queryErr := config.DataBase.Query(query, projectId, formName)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	submissions, scanErr := scanFormSubmissions(rows)
+	if scanErr != nil {
+		utils.HandleError(utils.ErrInternal, scanErr, w, nil)
+		return
+	}
+
+	fieldSet := map[string]bool{}
+	for _, submission := range submissions {
+		for field := range submission.Data {
+			fieldSet[field] = true
+		}
+	}
+
+	fields := make([]string, 0, len(fieldSet))
+	for field := range fieldSet {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="submissions-%s.csv"`, projectId))
+
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{"id", "form", "path", "created_at"}, fields...))
+
+	for _, submission := range submissions {
+		record := []string{strconv.Itoa(submission.Id), csvSafe(submission.FormName), submission.Path, submission.CreatedAt.Format(time.RFC3339)}
+		for _, field := range fields {
+			record = append(record, csvSafe(submission.Data[field]))
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+}
+
+func (p ProjectHandler) DeleteFormSubmission(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+	submissionId := chi.URLParam(r, "submissionId")
+
+	query := `
+		DELETE FROM "deploy-io".form_submissions s
+		WHERE s.id = $1 AND s.project_id = $2;
+	`
+	res, queryErr := config.DataBase.Exec(query, submissionId, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UpdateFormWebhook sets the https url every submission is posted to, null removes it
+func (p ProjectHandler) UpdateFormWebhook(w http.ResponseWriter, r *http.Request) {
+	projectId := chi.URLParam(r, "id")
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody UpdateFormWebhookBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	if requestBody.URL != nil {
+		parsed, parseErr := url.Parse(*requestBody.URL)
+		if parseErr != nil || parsed.Scheme != "https" || len(parsed.Host) == 0 {
+			errMsg := "url must be an absolute https url"
+			utils.HandleError(utils.ErrInvalid, parseErr, w, &errMsg)
+			return
+		}
+
+		// the static server posts submissions from inside our network, it must not be pointed back at it
+		if publicErr := outbound.CheckURL(*requestBody.URL); publicErr != nil {
+			errMsg := "url must point to a public address"
+			utils.HandleError(utils.ErrInvalid, publicErr, w, &errMsg)
+			return
+		}
+	}
+
+	query := `UPDATE "deploy-io".projects p SET form_webhook_url = $1 WHERE p.id = $2`
+	res, queryErr := config.DataBase.Exec(query, requestBody.URL, projectId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	rowsAffected, rowsAffectErr := res.RowsAffected()
+	if rowsAffectErr != nil {
+		utils.HandleError(utils.ErrInternal, rowsAffectErr, w, nil)
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, nil, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"msg": "Updated form webhook",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Analytics reads the hourly rollups written by the static server, `from` and `to` are RFC3339 times
+// and `interval` is either hour or day
+func (p ProjectHandler) Analytics(w http.ResponseWriter, r *http.Request) {
+	projectId, convErr := strconv.Atoi(chi.URLParam(r, "id"))
+	if convErr != nil {
+		utils.HandleError(utils.ErrInvalid, convErr, w, nil)
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := r.URL.Query().Get("to"); len(value) > 0 {
+		parsed, parseErr := time.Parse(time.RFC3339, value)
+		if parseErr != nil {
+			utils.HandleError(utils.ErrInvalid, parseErr, w, nil)
+			return
+		}
+		to = parsed.UTC()
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if value := r.URL.Query().Get("from"); len(value) > 0 {
+		parsed, parseErr := time.Parse(time.RFC3339, value)
+		if parseErr != nil {
+			utils.HandleError(utils.ErrInvalid, parseErr, w, nil)
+			return
+		}
+		from = parsed.UTC()
+	}
+
+	if !from.Before(to) || to.Sub(from) > 90*24*time.Hour {
+		errMsg := "from must be before to and at most 90 days apart"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if len(interval) == 0 {
+		interval = "hour"
+		if to.Sub(from) > 48*time.Hour {
+			interval = "day"
+		}
+	}
+
+	if interval != "hour" && interval != "day" {
+		errMsg := "interval must be hour or day"
+		utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+		return
+	}
+
+	// rollups are bucketed in utc, the times are passed without their zone
+	from = from.Truncate(time.Hour)
+
+	series := []TrafficBucket{}
+
+	seriesQuery := `
+		SELECT date_trunc($4, t.bucket) AS b, SUM(t.requests), SUM(t.page_views), SUM(t.bytes)
+		FROM "deploy-io".site_traffic_hourly t
+		WHERE t.project_id = $1 AND t.bucket >= $2 AND t.bucket < $3
+		GROUP BY b ORDER BY b;
+	`
+	seriesRows, seriesErr := config.DataBase.Query(seriesQuery, projectId, from, to, interval)
+	if seriesErr != nil {
+		utils.HandleError(utils.ErrInternal, seriesErr, w, nil)
+		return
+	}
+
+	defer seriesRows.Close()
+
+	for seriesRows.Next() {
+		var bucket TrafficBucket
+		if err := seriesRows.Scan(&bucket.Bucket, &bucket.Requests, &bucket.PageViews, &bucket.Bytes); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+		series = append(series, bucket)
+	}
+
+	statuses := map[string]int64{}
+	var totalRequests, totalBytes, totalLatency int64
+
+	statusQuery := `
+		SELECT t.status_class, SUM(t.requests), SUM(t.bytes), SUM(t.latency_ms)
+		FROM "deploy-io".site_traffic_hourly t
+		WHERE t.project_id = $1 AND t.bucket >= $2 AND t.bucket < $3
+		GROUP BY t.status_class;
+	`
+	statusRows, statusErr := config.DataBase.Query(statusQuery, projectId, from, to)
+	if statusErr != nil {
+		utils.HandleError(utils.ErrInternal, statusErr, w, nil)
+		return
+	}
+
+	defer statusRows.Close()
+
+	for statusRows.Next() {
+		var statusClass int
+		var requests, bytes, latency int64
+		if err := statusRows.Scan(&statusClass, &requests, &bytes, &latency); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		statuses[fmt.Sprintf("%dxx", statusClass)] = requests
+		totalRequests += requests
+		totalBytes += bytes
+		totalLatency += latency
+	}
+
+	pages := []PageStat{}
+
+	pagesQuery := `
+		SELECT pg.path, SUM(pg.requests) AS requests, SUM(pg.bytes)
+		FROM "deploy-io".site_pages_hourly pg
+		WHERE pg.project_id = $1 AND pg.bucket >= $2 AND pg.bucket < $3
+		GROUP BY pg.path ORDER BY requests DESC LIMIT 10;
+	`
+	pageRows, pagesErr := config.DataBase.Query(pagesQuery, projectId, from, to)
+	if pagesErr != nil {
+		utils.HandleError(utils.ErrInternal, pagesErr, w, nil)
+		return
+	}
+
+	defer pageRows.Close()
+
+	for pageRows.Next() {
+		var page PageStat
+		if err := pageRows.Scan(&page.Path, &page.Requests, &page.Bytes); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+		pages = append(pages, page)
+	}
+
+	referrers := []SourceStat{}
+	agents := map[string]int64{}
+
+	sourcesQuery := `
+		SELECT s.referrer, s.agent_class, SUM(s.requests)
+		FROM "deploy-io".site_sources_hourly s
+		WHERE s.project_id = $1 AND s.bucket >= $2 AND s.bucket < $3
+		GROUP BY s.referrer, s.agent_class;
+	`
+	sourceRows, sourcesErr := config.DataBase.Query(sourcesQuery, projectId, from, to)
+	if sourcesErr != nil {
+		utils.HandleError(utils.ErrInternal, sourcesErr, w, nil)
+		return
+	}
+
+	defer sourceRows.Close()
+
+	referrerTotals := map[string]int64{}
+
+	for sourceRows.Next() {
+		var referrer, agentClass string
+		var requests int64
+		if err := sourceRows.Scan(&referrer, &agentClass, &requests); err != nil {
+			utils.HandleError(utils.ErrInternal, err, w, nil)
+			return
+		}
+
+		agents[agentClass] += requests
+		if len(referrer) > 0 {
+			referrerTotals[referrer] += requests
+		}
+	}
+
+	for referrer, requests := range referrerTotals {
+		referrers = append(referrers, SourceStat{Referrer: referrer, Requests: requests})
+	}
+
+	sort.Slice(referrers, func(i, j int) bool {
+		return referrers[i].Requests > referrers[j].Requests
+	})
+
+	if len(referrers) > 10 {
+		referrers = referrers[:10]
+	}
+
+	var averageLatency int64
+	if totalRequests > 0 {
+		averageLatency = totalLatency / totalRequests
+	}
+
+	responseBody := map[string]any{
+		"from":               from,
+		"to":                 to,
+		"interval":           interval,
+		"series":             series,
+		"requests":           totalRequests,
+		"bandwidth":          totalBytes,
+		"average_latency_ms": averageLatency,
+		"statuses":           statuses,
+		"top_pages":          pages,
+		"top_referrers":      referrers,
+		"agents":             agents,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func insertProjectIntoDB(userId int, orgId *int, name string, repo gitprovider.Repo, repoToken *string, webhookSecret *string, installCommand string, buildCommand string, outputFolder string, nodeVersion string, directory string, spaFallback bool) (*int, error) {
+	var projectId int
+
+	// github_id is kept for GitHub projects so older readers of the column keep working
+	var githubId *int
+	var repoHost *string
+
+	if repo.Provider == "github" {
+		id, convErr := strconv.Atoi(repo.Id)
+		if convErr != nil {
+			return nil, convErr
+		}
+		githubId = &id
+	}
+
+	if len(repo.Host) > 0 {
+		repoHost = &repo.Host
+	}
+
+	query := `INSERT INTO "deploy-io".projects
+		(user_id, org_id, name, provider, repo, repo_host, repo_token, webhook_secret, github_id, install_command, build_command, output_folder, node_version, directory, spa_fallback)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`
+	err := config.DataBase.QueryRow(query, userId, orgId, name, repo.Provider, repo.Id, repoHost, repoToken, webhookSecret, githubId, installCommand, buildCommand, outputFolder, nodeVersion, directory, spaFallback).Scan(&projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &projectId, nil
+}
+
+// projectRepo validates the repository a new project is connected to
+func projectRepo(project Project, userId int) (gitprovider.Repo, error) {
+	repo := gitprovider.Repo{Provider: project.Provider, Id: strings.TrimSpace(project.Repo), UserId: userId}
+
+	if len(repo.Provider) == 0 {
+		repo.Provider = "github"
+	}
+
+	if repo.Provider == "github" && len(repo.Id) == 0 {
+		repo.Id = strings.TrimSpace(project.GithubId)
+	}
+
+	if len(repo.Id) == 0 {
+		return repo, fmt.Errorf("[PROJECT] repo is required")
+	}
+
+	if project.RepoToken != nil {
+		repo.Token = strings.TrimSpace(*project.RepoToken)
+	}
+
+	if project.RepoHost != nil && len(strings.TrimSpace(*project.RepoHost)) > 0 {
+		host, hostErr := gitprovider.HostURL(strings.TrimSpace(*project.RepoHost), "")
+		if hostErr != nil {
+			return repo, hostErr
+		}
+		repo.Host = host
+	}
+
+	switch repo.Provider {
+	case "github":
+		if _, err := strconv.Atoi(repo.Id); err != nil {
+			return repo, fmt.Errorf("[PROJECT] a GitHub repo is its numeric id")
+		}
+		if len(repo.Host) > 0 || len(repo.Token) > 0 {
+			return repo, fmt.Errorf("[PROJECT] GitHub repositories are read with the GitHub App or your sign in")
+		}
+	case "gitlab":
+		repo.Id = strings.Trim(repo.Id, "/")
+	case "gitea":
+		if len(repo.Host) == 0 {
+			return repo, fmt.Errorf("[PROJECT] a Gitea repo needs repo_host")
+		}
+		if parts := strings.Split(repo.Id, "/"); len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return repo, fmt.Errorf("[PROJECT] a Gitea repo is owner/name")
+		}
+	case "git":
+		if err := gitprovider.ValidGitURL(repo.Id); err != nil {
+			return repo, err
+		}
+		if len(repo.Host) > 0 || len(repo.Token) > 0 {
+			return repo, fmt.Errorf("[PROJECT] plain git repositories have to be public")
+		}
+	default:
+		return repo, fmt.Errorf("[PROJECT] provider can only be github, gitlab, gitea or git")
+	}
+
+	return repo, nil
+}
+
+func getDefaults() (string, string, string, string, string) {
+	var buildCommand, outputFolder string
+
+	installCommand, installCommandExists := os.LookupEnv("DEFAULT_INSTALL_COMMAND")
+	buildCommand, buildCommandExists := os.LookupEnv("DEFAULT_BUILD_COMMAND")
+	outputFolder, outputFolderExists := os.LookupEnv("DEFAULT_BUILD_DIRECTORY")
+	directory, directoryExists := os.LookupEnv("DEFAULT_PROJECT_DIRECTORY")
+	nodeVersion, nodeVersionExists := os.LookupEnv("DEFAULT_NODE_VERSION")
+
+	if !buildCommandExists || !outputFolderExists || !installCommandExists || !nodeVersionExists || !directoryExists {
+		return "npm install", "npm run build", "./dist", "20", "./"
+	}
+
+	return installCommand, buildCommand, outputFolder, nodeVersion, directory
+}
+
+func encrypt(text string) (string, error) {
+	key, keyExists := os.LookupEnv("ENV_SECRET")
+	if !keyExists {
+		return "", fmt.Errorf("[ENC] env secret is not accessible")
+	}
+
+	keyBytes := []byte(key)
+
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	cipherText := make([]byte, aes.BlockSize+len(text))
+	iv := cipherText[:aes.BlockSize]
+
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+
+	stream := cipher.NewCFBEncrypter(block, iv)
+	stream.XORKeyStream(cipherText[aes.BlockSize:], []byte(text))
+
+	return hex.EncodeToString(cipherText), nil
+}
+
+func scanFormSubmissions(rows *sql.Rows) ([]FormSubmission, error) {
+	submissions := []FormSubmission{}
+
+	for rows.Next() {
+		var submission FormSubmission
+		var data []byte
+
+		if err := rows.Scan(&submission.Id, &submission.FormName, &submission.Path, &data, &submission.UserAgent, &submission.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &submission.Data); err != nil {
+			return nil, err
+		}
+
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, rows.Err()
+}
+
+// csvSafe keeps spreadsheet apps from running submitted values as formulas
+func csvSafe(value string) string {
+	if len(value) > 0 && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// project names are used as the subdomain, so they have to be a single lowercase DNS label
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// `<project>-<buildId>` is the subdomain of a pinned build, a name ending that way could take over another project's builds
+var pinnedSuffixPattern = regexp.MustCompile(`-[0-9]+$`)
+
+func validateProjectName(name string) error {
+	if !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("name must be 1 to 63 lowercase letters, digits or dashes and can not start or end with a dash")
+	}
+
+	if pinnedSuffixPattern.MatchString(name) {
+		return fmt.Errorf("name can not end with a dash followed by digits, those subdomains serve pinned builds")
+	}
+
+	return nil
+}
+
+// isAliasTaken tells whether another project still redirects from the name after a rename
+func isAliasTaken(name string, projectId int) (bool, error) {
+	var taken bool
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM "deploy-io".project_aliases a WHERE a.name = $1 AND a.project_id <> $2 AND a.expires_at > NOW()
+		);
+	`
+	err := config.DataBase.QueryRow(query, name, projectId).Scan(&taken)
+
+	return taken, err
+}
+
+func getRenameRedirectDays() int {
+	days, convErr := strconv.Atoi(os.Getenv("RENAME_REDIRECT_DAYS"))
+	if convErr != nil || days < 1 {
+		return 30
+	}
+
+	return days
+}
+
+// node versions the build server installs through nvm, a minor or patch release of one of them is accepted too
+var supportedNodeVersions = []string{"16", "18", "20", "22"}
+
+var nodeVersionPattern = regexp.MustCompile(`^(\d+)(\.\d+){0,2}$`)
+
+// npm arguments are passed without a shell, this only keeps them readable and free of surprises
+var commandArgumentPattern = regexp.MustCompile(`^[A-Za-z0-9@._:/=+-]+$`)
+
+var relativePathPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]*$`)
+
+// validateSettings checks the build settings before they reach the build server,
+// the node version ends up in a shell there and the folders are joined to the checkout
+func validateSettings(installCommand, buildCommand, outputFolder, nodeVersion, directory string) error {
+	settings := [][2]string{
+		{"install_command", installCommand},
+		{"build_command", buildCommand},
+		{"node_version", nodeVersion},
+		{"output_folder", outputFolder},
+		{"directory", directory},
+	}
+
+	for _, setting := range settings {
+		if err := validateSetting(setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSetting checks a single build setting by its column name
+func validateSetting(name string, value string) error {
+	switch name {
+	case "install_command":
+		return validateCommand(name, value, []string{"install", "i", "ci"})
+	case "build_command":
+		return validateCommand(name, value, []string{"run", "run-script"})
+	case "node_version":
+		match := nodeVersionPattern.FindStringSubmatch(strings.TrimSpace(value))
+		if match == nil || !slices.Contains(supportedNodeVersions, match[1]) {
+			return fmt.Errorf("node_version must be one of %s", strings.Join(supportedNodeVersions, ", "))
+		}
+		return nil
+	default:
+		return validateRelativePath(name, value)
+	}
+}
+
+func validateCommand(name string, command string, subcommands []string) error {
+	fields := strings.Fields(command)
+
+	if len(fields) < 2 || fields[0] != "npm" || !slices.Contains(subcommands, fields[1]) {
+		return fmt.Errorf("%s must be one of npm %s", name, strings.Join(subcommands, ", npm "))
+	}
+
+	if (fields[1] == "run" || fields[1] == "run-script") && len(fields) < 3 {
+		return fmt.Errorf("%s must name the script to run", name)
+	}
+
+	for _, argument := range fields[2:] {
+		if !commandArgumentPattern.MatchString(argument) {
+			return fmt.Errorf("%s contains an unsupported argument %q", name, argument)
+		}
+	}
+
+	return nil
+}
+
+func validateRelativePath(name string, value string) error {
+	value = strings.TrimSpace(value)
+
+	if !relativePathPattern.MatchString(value) || strings.HasPrefix(value, "/") {
+		return fmt.Errorf("%s must be a relative path", name)
+	}
+
+	for _, segment := range strings.Split(value, "/") {
+		if segment == ".." {
+			return fmt.Errorf("%s can not leave the repository", name)
+		}
+	}
+
+	return nil
 }
 
 func removeLeadingAndTrailingSlashes(input string) string {
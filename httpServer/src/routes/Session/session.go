@@ -0,0 +1,30 @@
+package session
+
+import (
+	"httpServer/src/middleware"
+	auth "httpServer/src/routes/Auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// SessionRouter lists where the user is signed in and signs them out of any of those places
+func SessionRouter() chi.Router {
+	r := chi.NewRouter()
+
+	s := SessionHandler{}
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
+
+		r.Use(middleware.RequireScope(auth.ScopeAdmin))
+
+		r.Get("/all", s.ListSessions)
+		r.Delete("/{id}", s.RevokeSession)
+		r.Delete("/all", s.RevokeSessions)
+	})
+
+	return r
+}
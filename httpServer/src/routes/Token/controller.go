@@ -0,0 +1,169 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"httpServer/config"
+	auth "httpServer/src/routes/Auth"
+	"httpServer/utils"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+func (t TokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	query := `
+		SELECT t.id, t.name, t.scopes, t.expires_at, t.last_used_at, t.created_at
+		FROM "deploy-io".access_tokens t WHERE t.user_id = $1 ORDER BY t.id
+	`
+	rows, queryErr := config.DataBase.Query(query, *userId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+
+	for rows.Next() {
+		var token AccessToken
+		rowsErr := rows.Scan(&token.Id, &token.Name, pq.Array(&token.Scopes), &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+		if rowsErr != nil {
+			utils.HandleError(utils.ErrInternal, rowsErr, w, nil)
+			return
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	responseBody := map[string][]AccessToken{
+		"tokens": tokens,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (t TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	body, readBodyErr := io.ReadAll(r.Body)
+	if readBodyErr != nil {
+		utils.HandleError(utils.ErrInvalid, readBodyErr, w, nil)
+		return
+	}
+
+	var requestBody CreateTokenBody
+
+	jsonDestructErr := json.Unmarshal(body, &requestBody)
+	if jsonDestructErr != nil {
+		utils.HandleError(utils.ErrInvalid, jsonDestructErr, w, nil)
+		return
+	}
+
+	if len(strings.TrimSpace(requestBody.Name)) == 0 || len(requestBody.Scopes) == 0 || requestBody.ExpiresInDays < 0 {
+		utils.HandleError(utils.ErrInvalid, nil, w, nil)
+		return
+	}
+
+	for _, scope := range requestBody.Scopes {
+		if !auth.ValidScope(scope) {
+			errMsg := "Scopes can only be read, deploy or admin"
+			utils.HandleError(utils.ErrInvalid, nil, w, &errMsg)
+			return
+		}
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, tokenBytes); err != nil {
+		utils.HandleError(utils.ErrInternal, err, w, nil)
+		return
+	}
+
+	token := auth.AccessTokenPrefix + hex.EncodeToString(tokenBytes)
+
+	var expiresAt *time.Time
+	if requestBody.ExpiresInDays > 0 {
+		expiry := time.Now().AddDate(0, 0, requestBody.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	var tokenId int
+
+	query := `
+		INSERT INTO "deploy-io".access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id;
+	`
+	queryErr := config.DataBase.QueryRow(query, *userId, requestBody.Name, auth.HashAccessToken(token), pq.Array(requestBody.Scopes), expiresAt).Scan(&tokenId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	// the token is only ever shown here, the database keeps its hash
+	responseBody := map[string]any{
+		"id":         tokenId,
+		"token":      token,
+		"expires_at": expiresAt,
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (t TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userId := utils.GetUserIdFromContext(w, r)
+	if userId == nil {
+		return
+	}
+
+	tokenId := chi.URLParam(r, "id")
+
+	query := `DELETE FROM "deploy-io".access_tokens t WHERE t.id = $1 AND t.user_id = $2`
+	res, queryErr := config.DataBase.Exec(query, tokenId, *userId)
+	if queryErr != nil {
+		utils.HandleError(utils.ErrInternal, queryErr, w, nil)
+		return
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.HandleError(utils.ErrNotFound, err, w, nil)
+		return
+	}
+
+	responseBody := map[string]string{
+		"message": "Done",
+	}
+
+	response, constructorErr := json.Marshal(responseBody)
+	if constructorErr != nil {
+		utils.HandleError(utils.ErrInternal, constructorErr, w, nil)
+		return
+	}
+
+	w.Write(response)
+}
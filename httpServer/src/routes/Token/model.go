@@ -0,0 +1,21 @@
+package token
+
+import "time"
+
+type TokenHandler struct{}
+
+type CreateTokenBody struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// 0 creates a token that never expires
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type AccessToken struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
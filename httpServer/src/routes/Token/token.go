@@ -0,0 +1,30 @@
+package token
+
+import (
+	"httpServer/src/middleware"
+	auth "httpServer/src/routes/Auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// TokenRouter manages the personal access tokens the CLI and CI pipelines sign in with
+func TokenRouter() chi.Router {
+	r := chi.NewRouter()
+
+	t := TokenHandler{}
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
+
+		r.Use(middleware.RequireScope(auth.ScopeAdmin))
+
+		r.Get("/all", t.ListTokens)
+		r.Post("/new", t.CreateToken)
+		r.Delete("/{id}", t.RevokeToken)
+	})
+
+	return r
+}
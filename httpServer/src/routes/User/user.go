@@ -14,10 +14,10 @@ func UserRouter() chi.Router {
 	u := UserHandler{}
 
 	r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(auth.GetJWTAuthConfig()))
+		r.Use(middleware.Verifier(auth.GetJWTAuthConfig()))
 		r.Use(jwtauth.Authenticator(auth.GetJWTAuthConfig()))
+		r.Use(middleware.ActiveUser)
 
-		r.Use(middleware.GithubTokenValidation)
 		r.Get("/", u.GetDashboardDetails)
 	})
 
@@ -12,6 +12,7 @@ type ErrorType struct {
 var (
 	ErrUnAuthorized  = ErrorType{http.StatusUnauthorized, "Do I know you?"}
 	ErrInvalid       = ErrorType{http.StatusBadRequest, "Invalid request"}
+	ErrForbidden     = ErrorType{http.StatusForbidden, "Not yours to touch"}
 	ErrNotFound      = ErrorType{http.StatusNotFound, "Not found"}
 	ErrAlreadyExists = ErrorType{http.StatusConflict, "It's already there"}
 	TokenExpired     = ErrorType{498, "Trying to imitate someone?"}
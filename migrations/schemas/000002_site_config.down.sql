@@ -0,0 +1 @@
+ALTER TABLE "deploy-io".deployments DROP COLUMN IF EXISTS site_config;
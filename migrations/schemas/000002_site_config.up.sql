@@ -0,0 +1,2 @@
+-- Routing rules (redirects, rewrites) parsed by the build server are stored per deployment
+ALTER TABLE "deploy-io".deployments ADD COLUMN IF NOT EXISTS site_config JSONB NOT NULL DEFAULT '{}'::jsonb;
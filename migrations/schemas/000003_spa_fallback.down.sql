@@ -0,0 +1 @@
+ALTER TABLE "deploy-io".projects DROP COLUMN IF EXISTS spa_fallback;
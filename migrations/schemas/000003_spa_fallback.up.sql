@@ -0,0 +1,2 @@
+-- Serve index.html for unknown extensionless paths, single page apps rely on it
+ALTER TABLE "deploy-io".projects ADD COLUMN IF NOT EXISTS spa_fallback BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "deploy-io".bypass_tokens;
+
+ALTER TABLE "deploy-io".projects
+    DROP COLUMN IF EXISTS access_protection,
+    DROP COLUMN IF EXISTS access_password_hash;
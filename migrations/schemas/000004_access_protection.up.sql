@@ -0,0 +1,17 @@
+-- Sites can be locked behind a basic auth prompt or a login page
+ALTER TABLE "deploy-io".projects
+    ADD COLUMN IF NOT EXISTS access_protection VARCHAR NOT NULL DEFAULT 'none' CHECK (access_protection IN ('none', 'basic', 'login')),
+    ADD COLUMN IF NOT EXISTS access_password_hash VARCHAR NULL;
+
+-- Tokens that let automated tests through a protected site, only the sha256 of the token is kept
+CREATE TABLE IF NOT EXISTS "deploy-io".bypass_tokens (
+    id serial8,
+    project_id int8 NOT NULL,
+    name VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT bypass_tokens_pk PRIMARY KEY (id),
+    CONSTRAINT bypass_tokens_unique_hash UNIQUE (token_hash),
+    CONSTRAINT bypass_tokens_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE "deploy-io".builds DROP COLUMN IF EXISTS artifacts_kept;
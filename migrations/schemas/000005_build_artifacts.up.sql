@@ -0,0 +1,2 @@
+-- Every build uploads its files under its own prefix, older ones are pruned once they fall out of retention
+ALTER TABLE "deploy-io".builds ADD COLUMN IF NOT EXISTS artifacts_kept BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+ALTER TABLE "deploy-io".projects
+    DROP CONSTRAINT IF EXISTS projects_fk_canary_build,
+    DROP COLUMN IF EXISTS canary_build_id,
+    DROP COLUMN IF EXISTS canary_weight;
+
+ALTER TABLE "deploy-io".builds DROP COLUMN IF EXISTS canary;
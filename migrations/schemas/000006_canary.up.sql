@@ -0,0 +1,7 @@
+-- A build can be deployed as a candidate next to the active deployment and receive a share of the visitors
+ALTER TABLE "deploy-io".builds ADD COLUMN IF NOT EXISTS canary BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE "deploy-io".projects
+    ADD COLUMN IF NOT EXISTS canary_build_id int8 NULL,
+    ADD COLUMN IF NOT EXISTS canary_weight INT NOT NULL DEFAULT 0 CHECK (canary_weight BETWEEN 0 AND 100),
+    ADD CONSTRAINT projects_fk_canary_build FOREIGN KEY (canary_build_id) REFERENCES "deploy-io".builds(id) ON UPDATE CASCADE ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "deploy-io".site_sources_hourly;
+DROP TABLE IF EXISTS "deploy-io".site_pages_hourly;
+DROP TABLE IF EXISTS "deploy-io".site_traffic_hourly;
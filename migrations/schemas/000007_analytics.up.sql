@@ -0,0 +1,32 @@
+-- Hourly rollups of the static server's access events, written in batches by the static server
+CREATE TABLE IF NOT EXISTS "deploy-io".site_traffic_hourly (
+    project_id int8 NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    status_class SMALLINT NOT NULL,
+    requests int8 NOT NULL DEFAULT 0,
+    page_views int8 NOT NULL DEFAULT 0,
+    bytes int8 NOT NULL DEFAULT 0,
+    latency_ms int8 NOT NULL DEFAULT 0,
+    CONSTRAINT site_traffic_hourly_pk PRIMARY KEY (project_id, bucket, status_class),
+    CONSTRAINT site_traffic_hourly_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "deploy-io".site_pages_hourly (
+    project_id int8 NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    path VARCHAR NOT NULL,
+    requests int8 NOT NULL DEFAULT 0,
+    bytes int8 NOT NULL DEFAULT 0,
+    CONSTRAINT site_pages_hourly_pk PRIMARY KEY (project_id, bucket, path),
+    CONSTRAINT site_pages_hourly_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "deploy-io".site_sources_hourly (
+    project_id int8 NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    referrer VARCHAR NOT NULL,
+    agent_class VARCHAR NOT NULL,
+    requests int8 NOT NULL DEFAULT 0,
+    CONSTRAINT site_sources_hourly_pk PRIMARY KEY (project_id, bucket, referrer, agent_class),
+    CONSTRAINT site_sources_hourly_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
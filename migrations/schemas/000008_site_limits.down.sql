@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "deploy-io".site_bandwidth_usage;
+
+ALTER TABLE "deploy-io".projects
+    DROP COLUMN IF EXISTS rate_limit,
+    DROP COLUMN IF EXISTS rate_burst,
+    DROP COLUMN IF EXISTS bandwidth_quota,
+    DROP COLUMN IF EXISTS quota_page;
@@ -0,0 +1,15 @@
+-- Request rate and monthly bandwidth limits per project, NULL means the static server defaults apply
+ALTER TABLE "deploy-io".projects
+    ADD COLUMN IF NOT EXISTS rate_limit INT NULL CHECK (rate_limit > 0),
+    ADD COLUMN IF NOT EXISTS rate_burst INT NULL CHECK (rate_burst > 0),
+    ADD COLUMN IF NOT EXISTS bandwidth_quota int8 NULL CHECK (bandwidth_quota > 0),
+    ADD COLUMN IF NOT EXISTS quota_page TEXT NULL;
+
+-- Bytes served per project and month, every static server replica adds what it served
+CREATE TABLE IF NOT EXISTS "deploy-io".site_bandwidth_usage (
+    project_id int8 NOT NULL,
+    month DATE NOT NULL,
+    bytes int8 NOT NULL DEFAULT 0,
+    CONSTRAINT site_bandwidth_usage_pk PRIMARY KEY (project_id, month),
+    CONSTRAINT site_bandwidth_usage_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
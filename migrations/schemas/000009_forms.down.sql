@@ -0,0 +1,3 @@
+ALTER TABLE "deploy-io".projects DROP COLUMN IF EXISTS form_webhook_url;
+
+DROP TABLE IF EXISTS "deploy-io".form_submissions;
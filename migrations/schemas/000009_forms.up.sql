@@ -0,0 +1,17 @@
+-- Submissions of forms marked with data-deployio, the fields are kept as sent
+CREATE TABLE IF NOT EXISTS "deploy-io".form_submissions (
+    id serial8,
+    project_id int8 NOT NULL,
+    form_name VARCHAR NOT NULL,
+    path VARCHAR NOT NULL,
+    data JSONB NOT NULL,
+    user_agent VARCHAR NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT form_submissions_pk PRIMARY KEY (id),
+    CONSTRAINT form_submissions_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS form_submissions_project_idx ON "deploy-io".form_submissions (project_id, created_at DESC);
+
+-- Every submission is also posted here when set
+ALTER TABLE "deploy-io".projects ADD COLUMN IF NOT EXISTS form_webhook_url VARCHAR NULL;
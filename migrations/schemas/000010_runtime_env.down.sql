@@ -0,0 +1,3 @@
+ALTER TABLE "deploy-io".projects DROP COLUMN IF EXISTS inject_runtime_env;
+
+ALTER TABLE "deploy-io".environments DROP COLUMN IF EXISTS is_public_runtime;
@@ -0,0 +1,4 @@
+-- Public runtime variables are handed to the browser through window.__ENV when the project opts in
+ALTER TABLE "deploy-io".environments ADD COLUMN IF NOT EXISTS is_public_runtime BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE "deploy-io".projects ADD COLUMN IF NOT EXISTS inject_runtime_env BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+ALTER TABLE "deploy-io".projects
+    DROP COLUMN IF EXISTS maintenance_enabled,
+    DROP COLUMN IF EXISTS maintenance_starts_at,
+    DROP COLUMN IF EXISTS maintenance_ends_at,
+    DROP COLUMN IF EXISTS maintenance_page,
+    DROP COLUMN IF EXISTS maintenance_allowlist;
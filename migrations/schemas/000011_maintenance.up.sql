@@ -0,0 +1,7 @@
+-- Maintenance mode keeps the deployment but serves a 503 page, optionally only between starts_at and ends_at
+ALTER TABLE "deploy-io".projects
+    ADD COLUMN IF NOT EXISTS maintenance_enabled BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS maintenance_starts_at TIMESTAMPTZ NULL,
+    ADD COLUMN IF NOT EXISTS maintenance_ends_at TIMESTAMPTZ NULL,
+    ADD COLUMN IF NOT EXISTS maintenance_page TEXT NULL,
+    ADD COLUMN IF NOT EXISTS maintenance_allowlist TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,14 @@
+-- Every change to a project's build settings, changes maps a setting to its old and new value
+CREATE TABLE IF NOT EXISTS "deploy-io".project_history (
+    id serial8,
+    project_id int8 NOT NULL,
+    user_id int8 NOT NULL,
+    changes JSONB NOT NULL,
+    build_id int8 NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT project_history_pk PRIMARY KEY (id),
+    CONSTRAINT project_history_project_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT project_history_build_fk FOREIGN KEY (build_id) REFERENCES "deploy-io".builds(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS project_history_project_idx ON "deploy-io".project_history (project_id, created_at DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "deploy-io".project_aliases;
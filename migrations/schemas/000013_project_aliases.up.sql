@@ -0,0 +1,9 @@
+-- Names a project was renamed from, the old subdomain redirects to the new one until expires_at
+CREATE TABLE IF NOT EXISTS "deploy-io".project_aliases (
+    name VARCHAR NOT NULL,
+    project_id int8 NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT project_aliases_pk PRIMARY KEY (name),
+    CONSTRAINT project_aliases_fk FOREIGN KEY (project_id) REFERENCES "deploy-io".projects(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
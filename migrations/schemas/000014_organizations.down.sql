@@ -0,0 +1,5 @@
+ALTER TABLE "deploy-io".projects DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS "deploy-io".org_memberships;
+
+DROP TABLE IF EXISTS "deploy-io".organizations;
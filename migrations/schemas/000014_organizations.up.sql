@@ -0,0 +1,27 @@
+-- Organizations own projects shared by their members, each member has one role in the organization
+CREATE TABLE IF NOT EXISTS "deploy-io".organizations (
+    id serial8,
+    name VARCHAR NOT NULL,
+    slug VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT organizations_pk PRIMARY KEY (id),
+    CONSTRAINT organizations_unique_slug UNIQUE (slug)
+);
+
+CREATE TABLE IF NOT EXISTS "deploy-io".org_memberships (
+    org_id int8 NOT NULL,
+    user_id int8 NOT NULL,
+    role VARCHAR NOT NULL CHECK (role IN ('owner', 'admin', 'developer', 'viewer')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT org_memberships_pk PRIMARY KEY (org_id, user_id),
+    CONSTRAINT org_memberships_org_fk FOREIGN KEY (org_id) REFERENCES "deploy-io".organizations(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT org_memberships_user_fk FOREIGN KEY (user_id) REFERENCES "deploy-io".users(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS org_memberships_user_idx ON "deploy-io".org_memberships (user_id);
+
+-- A project with an org_id is owned by the organization, user_id stays the account whose GitHub access builds it.
+-- Without an org_id the project belongs to user_id alone, as every existing project does.
+ALTER TABLE "deploy-io".projects ADD COLUMN IF NOT EXISTS org_id int8 NULL REFERENCES "deploy-io".organizations(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS projects_org_idx ON "deploy-io".projects (org_id);
@@ -0,0 +1,3 @@
+ALTER TABLE "deploy-io".projects
+    DROP COLUMN IF EXISTS suspended,
+    DROP COLUMN IF EXISTS suspended_at;
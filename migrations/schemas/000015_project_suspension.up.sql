@@ -0,0 +1,4 @@
+-- A suspended project is no longer served by the static server, only a platform admin can lift it
+ALTER TABLE "deploy-io".projects
+    ADD COLUMN IF NOT EXISTS suspended BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "deploy-io".access_tokens;
@@ -0,0 +1,14 @@
+-- Personal access tokens for the CLI and CI pipelines, only the sha256 of the token is kept
+CREATE TABLE IF NOT EXISTS "deploy-io".access_tokens (
+    id serial8,
+    user_id int8 NOT NULL,
+    name VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT access_tokens_pk PRIMARY KEY (id),
+    CONSTRAINT access_tokens_unique_hash UNIQUE (token_hash),
+    CONSTRAINT access_tokens_fk FOREIGN KEY (user_id) REFERENCES "deploy-io".users(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
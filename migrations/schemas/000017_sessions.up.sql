@@ -0,0 +1,19 @@
+-- Every sign in starts a session, the short lived JWTs carry its id and are renewed with the session's refresh token.
+-- The refresh token rotates on every use, the previous hash is kept to spot a stolen token being replayed
+CREATE TABLE IF NOT EXISTS "deploy-io".sessions (
+    id serial8,
+    user_id int8 NOT NULL,
+    refresh_hash VARCHAR NOT NULL,
+    previous_refresh_hash VARCHAR NULL,
+    user_agent VARCHAR NULL,
+    ip_address VARCHAR NULL,
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT sessions_pk PRIMARY KEY (id),
+    CONSTRAINT sessions_unique_refresh_hash UNIQUE (refresh_hash),
+    CONSTRAINT sessions_fk FOREIGN KEY (user_id) REFERENCES "deploy-io".users(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_previous_refresh_hash_idx ON "deploy-io".sessions (previous_refresh_hash);
@@ -0,0 +1,9 @@
+-- github_id stays nullable, projects of other providers have none to restore
+DROP INDEX IF EXISTS "deploy-io".projects_provider_repo_idx;
+
+ALTER TABLE "deploy-io".projects
+    DROP COLUMN IF EXISTS provider,
+    DROP COLUMN IF EXISTS repo,
+    DROP COLUMN IF EXISTS repo_host,
+    DROP COLUMN IF EXISTS repo_token,
+    DROP COLUMN IF EXISTS webhook_secret;
@@ -0,0 +1,17 @@
+-- Projects name their git provider and the repository in that provider's terms, github_id is only kept for GitHub projects.
+-- repo is the numeric id on GitHub, the id or path on GitLab, owner/name on Gitea and the https url for plain git.
+-- repo_token and webhook_secret are encrypted with ENV_SECRET like environment values
+ALTER TABLE "deploy-io".projects
+    ADD COLUMN IF NOT EXISTS provider VARCHAR NOT NULL DEFAULT 'github' CHECK (provider IN ('github', 'gitlab', 'gitea', 'git')),
+    ADD COLUMN IF NOT EXISTS repo VARCHAR NULL,
+    ADD COLUMN IF NOT EXISTS repo_host VARCHAR NULL,
+    ADD COLUMN IF NOT EXISTS repo_token VARCHAR NULL,
+    ADD COLUMN IF NOT EXISTS webhook_secret VARCHAR NULL,
+    ALTER COLUMN github_id DROP NOT NULL,
+    ALTER COLUMN github_id DROP DEFAULT;
+
+UPDATE "deploy-io".projects SET repo = github_id::text WHERE repo IS NULL AND github_id IS NOT NULL;
+
+ALTER TABLE "deploy-io".projects ALTER COLUMN repo SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS projects_provider_repo_idx ON "deploy-io".projects (provider, COALESCE(repo_host, ''), repo);
@@ -0,0 +1,3 @@
+ALTER TABLE "deploy-io".project_aliases
+    ALTER COLUMN expires_at TYPE TIMESTAMP USING expires_at AT TIME ZONE 'Asia/Kolkata',
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'Asia/Kolkata';
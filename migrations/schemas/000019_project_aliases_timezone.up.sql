@@ -0,0 +1,5 @@
+-- expires_at was compared with NOW() as a plain timestamp, so the redirect ended at a time that depended on the
+-- session time zone. Stored values were written as Asia/Kolkata wall time, the time zone every server connects with
+ALTER TABLE "deploy-io".project_aliases
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'Asia/Kolkata',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'Asia/Kolkata';
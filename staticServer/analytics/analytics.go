@@ -0,0 +1,233 @@
+package analytics
+
+import (
+	"log"
+	"net/url"
+	"staticServer/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	flushInterval = 30 * time.Second
+	// distinct paths kept per project between two flushes, the rest is counted under otherPath
+	maxPagesPerFlush = 500
+	otherPath        = "(other)"
+	// referrers come from the client just like paths, so they are capped the same way
+	maxSourcesPerFlush = 500
+	otherReferrer      = "(other)"
+)
+
+// Event describes a single response served for a site
+type Event struct {
+	ProjectId int
+	Host      string
+	Path      string
+	Status    int
+	Bytes     int
+	PageView  bool
+	Referrer  string
+	UserAgent string
+	Latency   time.Duration
+	Time      time.Time
+}
+
+type trafficKey struct {
+	projectId   int
+	bucket      time.Time
+	statusClass int
+}
+
+type traffic struct {
+	requests  int64
+	pageViews int64
+	bytes     int64
+	latencyMs int64
+}
+
+type pageKey struct {
+	projectId int
+	bucket    time.Time
+	path      string
+}
+
+type page struct {
+	requests int64
+	bytes    int64
+}
+
+type sourceKey struct {
+	projectId  int
+	bucket     time.Time
+	referrer   string
+	agentClass string
+}
+
+type rollup struct {
+	traffic      map[trafficKey]*traffic
+	pages        map[pageKey]*page
+	sources      map[sourceKey]int64
+	pagesPerSite map[int]int
+	// distinct referrers per project, agent classes are a fixed handful and not counted
+	referrersPerSite map[int]map[string]bool
+}
+
+var (
+	mutex   sync.Mutex
+	current = newRollup()
+)
+
+func newRollup() *rollup {
+	return &rollup{
+		traffic:          map[trafficKey]*traffic{},
+		pages:            map[pageKey]*page{},
+		sources:          map[sourceKey]int64{},
+		pagesPerSite:     map[int]int{},
+		referrersPerSite: map[int]map[string]bool{},
+	}
+}
+
+// Start writes the collected events to the database every flushInterval
+func Start() {
+	go func() {
+		for range time.Tick(flushInterval) {
+			Flush()
+		}
+	}()
+}
+
+// Record adds the event to the current rollup, it never touches the database
+func Record(event Event) {
+	bucket := event.Time.UTC().Truncate(time.Hour)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tKey := trafficKey{projectId: event.ProjectId, bucket: bucket, statusClass: event.Status / 100}
+	t, found := current.traffic[tKey]
+	if !found {
+		t = &traffic{}
+		current.traffic[tKey] = t
+	}
+
+	t.requests++
+	t.bytes += int64(event.Bytes)
+	t.latencyMs += event.Latency.Milliseconds()
+	if event.PageView {
+		t.pageViews++
+	}
+
+	pKey := pageKey{projectId: event.ProjectId, bucket: bucket, path: event.Path}
+	p, found := current.pages[pKey]
+	if !found {
+		if current.pagesPerSite[event.ProjectId] >= maxPagesPerFlush {
+			pKey.path = otherPath
+			p = current.pages[pKey]
+		} else {
+			current.pagesPerSite[event.ProjectId]++
+		}
+
+		if p == nil {
+			p = &page{}
+			current.pages[pKey] = p
+		}
+	}
+
+	p.requests++
+	p.bytes += int64(event.Bytes)
+
+	referrer := referrerHost(event.Referrer, event.Host)
+
+	referrers, found := current.referrersPerSite[event.ProjectId]
+	if !found {
+		referrers = map[string]bool{}
+		current.referrersPerSite[event.ProjectId] = referrers
+	}
+
+	if !referrers[referrer] {
+		if len(referrers) >= maxSourcesPerFlush {
+			referrer = otherReferrer
+		} else {
+			referrers[referrer] = true
+		}
+	}
+
+	sKey := sourceKey{projectId: event.ProjectId, bucket: bucket, referrer: referrer, agentClass: AgentClass(event.UserAgent)}
+	current.sources[sKey]++
+}
+
+// Flush writes the collected rollup, rows that fail to be written are dropped
+func Flush() {
+	mutex.Lock()
+	pending := current
+	current = newRollup()
+	mutex.Unlock()
+
+	trafficQuery := `
+		INSERT INTO "deploy-io".site_traffic_hourly AS t (project_id, bucket, status_class, requests, page_views, bytes, latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (project_id, bucket, status_class) DO UPDATE SET
+			requests = t.requests + EXCLUDED.requests, page_views = t.page_views + EXCLUDED.page_views,
+			bytes = t.bytes + EXCLUDED.bytes, latency_ms = t.latency_ms + EXCLUDED.latency_ms;
+	`
+	for key, value := range pending.traffic {
+		_, err := config.DataBase.Exec(trafficQuery, key.projectId, key.bucket, key.statusClass, value.requests, value.pageViews, value.bytes, value.latencyMs)
+		if err != nil {
+			log.Println("[ANALYTICS] " + err.Error())
+		}
+	}
+
+	pagesQuery := `
+		INSERT INTO "deploy-io".site_pages_hourly AS p (project_id, bucket, path, requests, bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id, bucket, path) DO UPDATE SET requests = p.requests + EXCLUDED.requests, bytes = p.bytes + EXCLUDED.bytes;
+	`
+	for key, value := range pending.pages {
+		_, err := config.DataBase.Exec(pagesQuery, key.projectId, key.bucket, key.path, value.requests, value.bytes)
+		if err != nil {
+			log.Println("[ANALYTICS] " + err.Error())
+		}
+	}
+
+	sourcesQuery := `
+		INSERT INTO "deploy-io".site_sources_hourly AS s (project_id, bucket, referrer, agent_class, requests)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id, bucket, referrer, agent_class) DO UPDATE SET requests = s.requests + EXCLUDED.requests;
+	`
+	for key, requests := range pending.sources {
+		_, err := config.DataBase.Exec(sourcesQuery, key.projectId, key.bucket, key.referrer, key.agentClass, requests)
+		if err != nil {
+			log.Println("[ANALYTICS] " + err.Error())
+		}
+	}
+}
+
+// AgentClass reduces a user agent to bot, mobile, desktop or other
+func AgentClass(userAgent string) string {
+	agent := strings.ToLower(userAgent)
+
+	switch {
+	case len(agent) == 0:
+		return "other"
+	case strings.Contains(agent, "bot") || strings.Contains(agent, "crawl") || strings.Contains(agent, "spider") ||
+		strings.Contains(agent, "curl") || strings.Contains(agent, "wget") || strings.Contains(agent, "python"):
+		return "bot"
+	case strings.Contains(agent, "mobi") || strings.Contains(agent, "android") || strings.Contains(agent, "iphone"):
+		return "mobile"
+	case strings.Contains(agent, "mozilla"):
+		return "desktop"
+	}
+
+	return "other"
+}
+
+// referrerHost keeps only the host of external referrers, navigation within the site is not a source
+func referrerHost(referrer, host string) string {
+	parsed, err := url.Parse(referrer)
+	if err != nil || len(parsed.Hostname()) == 0 || strings.EqualFold(parsed.Hostname(), host) {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
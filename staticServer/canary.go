@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math/rand/v2"
+	"staticServer/site"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	variantCookieName = "__deployio_variant"
+	// long enough for a visit to stay on one deployment, short enough for weight changes to reach everyone
+	variantDuration = 24 * time.Hour
+)
+
+// pickDeployment assigns the visitor to the active deployment or the canary according to the project's
+// canary weight. The choice is kept in a cookie so a visitor does not switch between them on every request.
+func pickDeployment(c fiber.Ctx, activeSite *site.Site) *site.Site {
+	if activeSite.Canary == nil {
+		return activeSite
+	}
+
+	// the same url serves different content, shared caches have to tell visitors apart
+	c.Vary("Cookie")
+
+	switch c.Cookies(variantCookieName) {
+	case strconv.Itoa(activeSite.BuildId):
+		return activeSite
+	case strconv.Itoa(activeSite.Canary.BuildId):
+		return activeSite.Canary
+	}
+
+	chosen := activeSite
+	if rand.IntN(100) < activeSite.CanaryWeight {
+		chosen = activeSite.Canary
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     variantCookieName,
+		Value:    strconv.Itoa(chosen.BuildId),
+		Path:     "/",
+		Expires:  time.Now().Add(variantDuration),
+		Secure:   c.Secure(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return chosen
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"staticServer/config"
+	"staticServer/limits"
+	"staticServer/outbound"
+	"staticServer/site"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxFormBody   = 64 * 1024
+	maxFormFields = 50
+)
+
+// the project chooses the url, so the client refuses internal addresses at the moment it connects,
+// a check when the url was saved alone would miss names that resolve differently later
+var webhookClient = outbound.HTTPClient(10 * time.Second)
+
+// submitForm stores a POST to a path the build marked as a form. Spam caught by the honeypot
+// gets the same answer as a real submission so bots can not tell the difference.
+func submitForm(c fiber.Ctx, activeSite *site.Site) error {
+	form, found := activeSite.Config.FormFor(cleanPath(c.Path()))
+	if !found {
+		return c.SendStatus(fiber.StatusMethodNotAllowed)
+	}
+
+	if len(c.Body()) > maxFormBody {
+		return c.Status(fiber.StatusRequestEntityTooLarge).SendString("Submission is too large")
+	}
+
+	if !limits.AllowSubmission(activeSite.ProjectId, c.IP()) {
+		c.Set("Retry-After", "600")
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many submissions")
+	}
+
+	data, parseErr := formData(c)
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid submission")
+	}
+
+	if len(data[site.HoneypotField]) == 0 {
+		delete(data, site.HoneypotField)
+
+		if err := saveSubmission(c, activeSite, form, data); err != nil {
+			return unavailable(c, err)
+		}
+	}
+
+	if strings.Contains(c.Get(fiber.HeaderAccept), fiber.MIMEApplicationJSON) {
+		return c.JSON(fiber.Map{"ok": true})
+	}
+
+	redirect := form.Redirect
+	if len(redirect) == 0 {
+		redirect = form.Page
+	}
+
+	return c.Redirect().Status(fiber.StatusSeeOther).To(redirect)
+}
+
+// formData reads url encoded and multipart bodies, files are ignored and repeated fields are joined
+func formData(c fiber.Ctx) (map[string]string, error) {
+	data := map[string]string{}
+
+	add := func(key, value string) {
+		if existing, exists := data[key]; exists {
+			value = existing + ", " + value
+		}
+		data[key] = value
+	}
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		multipartForm, err := c.MultipartForm()
+		if err != nil {
+			return nil, err
+		}
+
+		for key, values := range multipartForm.Value {
+			for _, value := range values {
+				add(key, value)
+			}
+		}
+	} else {
+		c.Request().PostArgs().VisitAll(func(key, value []byte) {
+			add(string(key), string(value))
+		})
+	}
+
+	if len(data) > maxFormFields {
+		return nil, fiber.ErrBadRequest
+	}
+
+	return data, nil
+}
+
+func saveSubmission(c fiber.Ctx, activeSite *site.Site, form *site.Form, data map[string]string) error {
+	encoded, marshalErr := json.Marshal(data)
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	var submissionId int
+	var createdAt time.Time
+
+	query := `
+		INSERT INTO "deploy-io".form_submissions (project_id, form_name, path, data, user_agent)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at;
+	`
+	err := config.DataBase.QueryRow(query, activeSite.ProjectId, form.Name, form.Action, string(encoded), c.Get(fiber.HeaderUserAgent)).Scan(&submissionId, &createdAt)
+	if err != nil {
+		return err
+	}
+
+	if len(activeSite.FormWebhookURL) > 0 {
+		payload := map[string]any{
+			"id":         submissionId,
+			"site":       activeSite.Name,
+			"form":       form.Name,
+			"path":       form.Action,
+			"data":       data,
+			"created_at": createdAt,
+		}
+
+		go forwardSubmission(activeSite.FormWebhookURL, payload)
+	}
+
+	return nil
+}
+
+// forwardSubmission posts the submission to the project's webhook, the submission is already stored so failures are only logged
+func forwardSubmission(webhookURL string, payload map[string]any) {
+	body, marshalErr := json.Marshal(payload)
+	if marshalErr != nil {
+		log.Println("[FORMS] " + marshalErr.Error())
+		return
+	}
+
+	request, requestErr := http.NewRequestWithContext(context.Background(), http.MethodPost, webhookURL, bytes.NewReader(body))
+	if requestErr != nil {
+		log.Println("[FORMS] " + requestErr.Error())
+		return
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "deployio-forms")
+
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		log.Println("[FORMS] webhook failed " + err.Error())
+		return
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		log.Printf("[FORMS] webhook answered with %d\n", response.StatusCode)
+	}
+}
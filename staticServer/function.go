@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"staticServer/functions"
+	"staticServer/site"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// headers the static server sets itself, a function can not override them
+var functionReservedHeaders = map[string]bool{
+	"content-length":    true,
+	"content-encoding":  true,
+	"transfer-encoding": true,
+	"connection":        true,
+}
+
+// runFunction answers the request with the deployment's WASI module for the route
+func runFunction(c fiber.Ctx, activeSite *site.Site, function *site.Function) error {
+	env, envErr := activeSite.Environment()
+	if envErr != nil {
+		return unavailable(c, envErr)
+	}
+
+	headers := map[string]string{}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		name := string(key)
+
+		// the credentials of the site's protection are not the function's business
+		switch {
+		case strings.EqualFold(name, bypassHeader):
+			return
+		case strings.EqualFold(name, fiber.HeaderAuthorization) && activeSite.Protection == site.ProtectionBasic:
+			return
+		case strings.EqualFold(name, fiber.HeaderCookie):
+			if cookies := withoutAccessCookie(string(value)); len(cookies) > 0 {
+				headers[name] = cookies
+			}
+			return
+		}
+
+		headers[name] = string(value)
+	})
+
+	request := functions.Request{
+		Method:  c.Method(),
+		Path:    c.Path(),
+		Query:   string(c.Request().URI().QueryString()),
+		Headers: headers,
+		Body:    string(c.Body()),
+	}
+
+	load := func() ([]byte, error) {
+		file, err := getFile(function.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		return file.Content, nil
+	}
+
+	response, runErr := functions.Run(c.Context(), function.Object, load, env, request)
+	if runErr == functions.ErrTimeout {
+		return c.Status(fiber.StatusGatewayTimeout).SendString("Function timed out")
+	}
+	if runErr == functions.ErrCPUTime {
+		return c.Status(fiber.StatusGatewayTimeout).SendString("Function used up its execution time")
+	}
+	if runErr != nil {
+		fmt.Println("[FUNCTIONS] " + runErr.Error())
+		return c.Status(fiber.StatusBadGateway).SendString("Function failed")
+	}
+
+	if response.Status < 100 || response.Status > 599 {
+		fmt.Printf("[FUNCTIONS] %s answered with status %d\n", function.Object, response.Status)
+		return c.Status(fiber.StatusBadGateway).SendString("Function failed")
+	}
+
+	// responses are dynamic unless the function says otherwise
+	c.Set("Cache-Control", "no-store")
+
+	for name, value := range response.Headers {
+		if functionReservedHeaders[strings.ToLower(name)] || strings.ContainsAny(value, "\r\n") {
+			continue
+		}
+		c.Set(name, value)
+	}
+
+	return c.Status(response.Status).SendString(response.Body)
+}
+
+// withoutAccessCookie drops the protection's access cookie from a Cookie header and keeps the others as they were
+func withoutAccessCookie(header string) string {
+	var kept []string
+
+	for _, cookie := range strings.Split(header, ";") {
+		cookie = strings.TrimSpace(cookie)
+		name, _, _ := strings.Cut(cookie, "=")
+
+		if len(cookie) == 0 || strings.TrimSpace(name) == accessCookieName {
+			continue
+		}
+		kept = append(kept, cookie)
+	}
+
+	return strings.Join(kept, "; ")
+}
@@ -0,0 +1,112 @@
+package functions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// how often a running invocation's budget is checked
+const budgetCheckInterval = 5 * time.Millisecond
+
+// budget is the execution time an invocation may spend running its own code. Time spent in WASI calls,
+// reading the request, writing the response or sleeping, is not charged, so a function that computes
+// is stopped once the budget is used while one that waits only runs into the wall clock timeout.
+// wazero has no fuel or instruction counter, the time between host calls is what the guest ran for
+type budget struct {
+	mutex     sync.Mutex
+	limit     time.Duration
+	started   time.Time
+	inHost    time.Duration
+	hostSince time.Time
+	depth     int
+}
+
+type budgetKey struct{}
+
+func newBudget(limit time.Duration) *budget {
+	return &budget{limit: limit, started: time.Now()}
+}
+
+// used is the time the guest has run for so far
+func (b *budget) used() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	used := now.Sub(b.started) - b.inHost
+	if b.depth > 0 {
+		used -= now.Sub(b.hostSince)
+	}
+
+	return used
+}
+
+func (b *budget) enterHost() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.depth++
+	if b.depth == 1 {
+		b.hostSince = time.Now()
+	}
+}
+
+func (b *budget) leaveHost() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.depth--
+	if b.depth == 0 {
+		b.inHost += time.Since(b.hostSince)
+	}
+}
+
+// watch stops the invocation with ErrCPUTime once the budget is used up, it returns when ctx is done
+func (b *budget) watch(ctx context.Context, stop context.CancelCauseFunc) {
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if b.used() > b.limit {
+				stop(ErrCPUTime)
+				return
+			}
+		}
+	}
+}
+
+// hostListeners is installed on the WASI host module, it tells the invocation's budget when the guest
+// leaves for a host call and when it comes back
+type hostListeners struct{}
+
+func (hostListeners) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return hostListener{}
+}
+
+type hostListener struct{}
+
+func (hostListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+	if b, found := ctx.Value(budgetKey{}).(*budget); found {
+		b.enterHost()
+	}
+}
+
+func (hostListener) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64) {
+	if b, found := ctx.Value(budgetKey{}).(*budget); found {
+		b.leaveHost()
+	}
+}
+
+func (hostListener) Abort(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error) {
+	if b, found := ctx.Value(budgetKey{}).(*budget); found {
+		b.leaveHost()
+	}
+}
@@ -0,0 +1,260 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	// 64KiB pages, 2048 pages are 128MiB of memory per invocation
+	memoryLimitPages = 2048
+	maxOutput        = 6 << 20
+	maxStderr        = 16 << 10
+	// compiled modules kept in memory, the cache is cleared when it fills up
+	maxCompiled = 64
+)
+
+var ErrTimeout = errors.New("[FUNCTIONS] function did not finish in time")
+
+var ErrCPUTime = errors.New("[FUNCTIONS] function used up its execution time")
+
+// Request is written to the module's stdin as json
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// Response is read from the module's stdout, output that is not json is sent as a text body
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+var (
+	runtime wazero.Runtime
+	// wall clock time an invocation may take, waiting for a slot and host calls included
+	wallClockTimeout = 10 * time.Second
+	// time an invocation may spend running its own code, see budget
+	cpuTime = 2 * time.Second
+	// caps the invocations running at once, every invocation holds one slot while it runs
+	slots chan struct{}
+
+	mutex    sync.Mutex
+	compiled = map[string]*compiledEntry{}
+)
+
+// evicted modules are closed once the last invocation using them is done
+type compiledEntry struct {
+	module  wazero.CompiledModule
+	users   int
+	evicted bool
+}
+
+// Init creates the runtime, FUNCTION_TIMEOUT is the wall clock timeout in seconds, FUNCTION_CPU_TIME the
+// execution budget in milliseconds and FUNCTION_CONCURRENCY caps parallel invocations
+func Init() {
+	ctx := context.Background()
+
+	runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(memoryLimitPages).
+		WithCloseOnContextDone(true))
+
+	// only the host functions are listened to, calls within the guest run at full speed
+	wasi_snapshot_preview1.MustInstantiate(experimental.WithFunctionListenerFactory(ctx, hostListeners{}), runtime)
+
+	if value, err := strconv.Atoi(os.Getenv("FUNCTION_TIMEOUT")); err == nil && value > 0 {
+		wallClockTimeout = time.Duration(value) * time.Second
+	}
+
+	if value, err := strconv.Atoi(os.Getenv("FUNCTION_CPU_TIME")); err == nil && value > 0 {
+		cpuTime = time.Duration(value) * time.Millisecond
+	}
+
+	concurrency := 16
+	if value, err := strconv.Atoi(os.Getenv("FUNCTION_CONCURRENCY")); err == nil && value > 0 {
+		concurrency = value
+	}
+
+	slots = make(chan struct{}, concurrency)
+}
+
+// Run instantiates the module once for the request, the module reads the request from stdin and exits after writing its response
+func Run(ctx context.Context, object string, load func() ([]byte, error), env map[string]string, request Request) (*Response, error) {
+	entry, compileErr := acquire(ctx, object, load)
+	if compileErr != nil {
+		return nil, compileErr
+	}
+
+	defer release(entry)
+
+	input, marshalErr := json.Marshal(request)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, wallClockTimeout)
+	defer cancel()
+
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		return nil, ErrTimeout
+	}
+
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	invocationBudget := newBudget(cpuTime)
+	ctx = context.WithValue(ctx, budgetKey{}, invocationBudget)
+	go invocationBudget.watch(ctx, stop)
+
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: maxStderr}
+
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(object).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithRandSource(rand.Reader).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep()
+
+	for key, value := range env {
+		config = config.WithEnv(key, value)
+	}
+
+	instance, runErr := runtime.InstantiateModule(ctx, entry.module, config)
+	if instance != nil {
+		instance.Close(context.Background())
+	}
+
+	if stderr.Len() > 0 {
+		log.Printf("[FUNCTIONS] %s: %s\n", object, stderr.String())
+	}
+
+	if context.Cause(ctx) == ErrCPUTime {
+		return nil, ErrCPUTime
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, ErrTimeout
+	}
+
+	var exitErr *sys.ExitError
+	if errors.As(runErr, &exitErr) && exitErr.ExitCode() != 0 {
+		return nil, fmt.Errorf("[FUNCTIONS] %s exited with %d", object, exitErr.ExitCode())
+	}
+	if runErr != nil {
+		return nil, runErr
+	}
+
+	if stdout.exceeded {
+		return nil, fmt.Errorf("[FUNCTIONS] %s wrote more than %d bytes", object, maxOutput)
+	}
+
+	var response Response
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return &Response{Status: 200, Headers: map[string]string{"Content-Type": "text/plain; charset=utf-8"}, Body: stdout.String()}, nil
+	}
+
+	if response.Status == 0 {
+		response.Status = 200
+	}
+
+	return &response, nil
+}
+
+// acquire returns the compiled module for the object, objects never change since every build has its own prefix
+func acquire(ctx context.Context, object string, load func() ([]byte, error)) (*compiledEntry, error) {
+	mutex.Lock()
+	if entry, found := compiled[object]; found {
+		entry.users++
+		mutex.Unlock()
+		return entry, nil
+	}
+	mutex.Unlock()
+
+	binary, loadErr := load()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	module, compileErr := runtime.CompileModule(ctx, binary)
+	if compileErr != nil {
+		return nil, compileErr
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// another request compiled the same object in the meantime
+	if entry, found := compiled[object]; found {
+		module.Close(ctx)
+		entry.users++
+		return entry, nil
+	}
+
+	if len(compiled) >= maxCompiled {
+		for name, stale := range compiled {
+			stale.evicted = true
+			if stale.users == 0 {
+				stale.module.Close(context.Background())
+			}
+			delete(compiled, name)
+		}
+	}
+
+	entry := &compiledEntry{module: module, users: 1}
+	compiled[object] = entry
+
+	return entry, nil
+}
+
+func release(entry *compiledEntry) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	entry.users--
+	if entry.evicted && entry.users == 0 {
+		entry.module.Close(context.Background())
+	}
+}
+
+// limitedBuffer keeps the first limit bytes and remembers whether more were written
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); len(p) > remaining {
+		b.exceeded = true
+		b.Buffer.Write(p[:max(remaining, 0)])
+		return len(p), nil
+	}
+
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,95 @@
+package functions
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// a module whose _start is `loop br 0 end`, it never returns and never calls the host
+var infiniteLoop = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// type section: one func type, no params and no results
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+	// function section: one function of type 0
+	0x03, 0x02, 0x01, 0x00,
+	// export section: function 0 as _start
+	0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00,
+	// code section: no locals, loop, br 0, end, end
+	0x0a, 0x09, 0x01, 0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b,
+}
+
+// the same module with an empty _start
+var returns = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+	0x03, 0x02, 0x01, 0x00,
+	0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00,
+	0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b,
+}
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+func TestInfiniteLoopIsStoppedByBudget(t *testing.T) {
+	defer setLimits(100*time.Millisecond, 10*time.Second)()
+
+	started := time.Now()
+	_, err := Run(context.Background(), "loop.wasm", load(infiniteLoop), nil, Request{Method: "GET", Path: "/api/loop"})
+
+	if err != ErrCPUTime {
+		t.Fatalf("err = %v, want ErrCPUTime", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("the loop ran for %s, the budget is 100ms", elapsed)
+	}
+}
+
+func TestWallClockTimeoutStillApplies(t *testing.T) {
+	defer setLimits(10*time.Second, 100*time.Millisecond)()
+
+	_, err := Run(context.Background(), "loop-wall.wasm", load(infiniteLoop), nil, Request{Method: "GET", Path: "/api/loop"})
+	if err != ErrTimeout {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+}
+
+func TestModuleWithinBudgetRuns(t *testing.T) {
+	defer setLimits(100*time.Millisecond, 10*time.Second)()
+
+	response, err := Run(context.Background(), "returns.wasm", load(returns), nil, Request{Method: "GET", Path: "/api/ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Status != 200 {
+		t.Errorf("status = %d, want 200", response.Status)
+	}
+}
+
+func TestHostCallsAreNotCharged(t *testing.T) {
+	b := newBudget(time.Second)
+
+	b.enterHost()
+	time.Sleep(50 * time.Millisecond)
+	b.leaveHost()
+
+	if used := b.used(); used > 25*time.Millisecond {
+		t.Errorf("used = %s, time in the host should not count", used)
+	}
+}
+
+func load(module []byte) func() ([]byte, error) {
+	return func() ([]byte, error) { return module, nil }
+}
+
+func setLimits(cpu time.Duration, wallClock time.Duration) func() {
+	previousCPU, previousWallClock := cpuTime, wallClockTimeout
+	cpuTime, wallClockTimeout = cpu, wallClock
+
+	return func() { cpuTime, wallClockTimeout = previousCPU, previousWallClock }
+}
@@ -0,0 +1,282 @@
+package limits
+
+import (
+	"log"
+	"math"
+	"os"
+	"staticServer/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const flushInterval = 15 * time.Second
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type usage struct {
+	month time.Time
+	// bytes stored in the database by every replica as of the last flush
+	persisted int64
+	// bytes served by this replica since the last flush
+	pending int64
+}
+
+var (
+	mutex   sync.Mutex
+	buckets = map[int]*bucket{}
+	usages  = map[int]*usage{}
+
+	defaultRate float64
+	replicas    = 1
+)
+
+// Start reads the defaults from the environment and persists bandwidth usage every flushInterval.
+// DEFAULT_RATE_LIMIT applies to projects without their own limit. Buckets are kept per replica and are
+// not shared, STATIC_REPLICAS only divides every rate and burst by the number of replicas. The limit
+// holds when the load balancer spreads a project's requests evenly, a replica that gets more than its
+// share turns requests away early and one that gets less lets the project stay below its limit.
+func Start() {
+	if value, err := strconv.ParseFloat(os.Getenv("DEFAULT_RATE_LIMIT"), 64); err == nil && value > 0 {
+		defaultRate = value
+	}
+
+	if value, err := strconv.Atoi(os.Getenv("STATIC_REPLICAS")); err == nil && value > 0 {
+		replicas = value
+	}
+
+	go func() {
+		for range time.Tick(flushInterval) {
+			Flush()
+		}
+	}()
+}
+
+// Allow takes a token from the project's bucket on this replica, a rate of zero falls back to the default.
+// The limit across all replicas is an approximation, see Start
+func Allow(projectId int, rate int, burst int) bool {
+	perSecond := float64(rate)
+	if perSecond == 0 {
+		perSecond = defaultRate
+	}
+	if perSecond == 0 {
+		return true
+	}
+
+	perSecond = perSecond / float64(replicas)
+
+	capacity := float64(burst) / float64(replicas)
+	if capacity < perSecond {
+		capacity = perSecond
+	}
+	capacity = math.Max(capacity, 1)
+
+	now := time.Now()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	b, found := buckets[projectId]
+	if !found {
+		b = &bucket{tokens: capacity, updatedAt: now}
+		buckets[projectId] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*perSecond)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// AddBandwidth counts bytes served for the project in the current month
+func AddBandwidth(projectId int, bytes int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	current(projectId).pending += int64(bytes)
+}
+
+// QuotaExceeded reports whether the project served more than quota bytes this month across all replicas
+func QuotaExceeded(projectId int, quota int64) bool {
+	if quota <= 0 {
+		return false
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	u := current(projectId)
+
+	return u.persisted+u.pending >= quota
+}
+
+// Flush adds the pending usage of every project to the database and reads back the total of all replicas
+func Flush() {
+	mutex.Lock()
+	flushing := map[int]usage{}
+	for projectId, u := range usages {
+		flushing[projectId] = *u
+		u.pending = 0
+	}
+	mutex.Unlock()
+
+	query := `
+		INSERT INTO "deploy-io".site_bandwidth_usage AS u (project_id, month, bytes) VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, month) DO UPDATE SET bytes = u.bytes + EXCLUDED.bytes
+		RETURNING u.bytes;
+	`
+
+	for projectId, u := range flushing {
+		var total int64
+
+		err := config.DataBase.QueryRow(query, projectId, u.month, u.pending).Scan(&total)
+
+		mutex.Lock()
+		stored := usages[projectId]
+		if err != nil {
+			// handed back so the bytes are written with the next flush
+			if stored.month.Equal(u.month) {
+				stored.pending += u.pending
+			}
+			log.Println("[LIMITS] " + err.Error())
+		} else if stored.month.Equal(u.month) {
+			stored.persisted = total
+		}
+		mutex.Unlock()
+	}
+}
+
+// current returns the project's usage of this month, the caller holds the mutex
+func current(projectId int) *usage {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	u, found := usages[projectId]
+	if !found || !u.month.Equal(month) {
+		u = &usage{month: month}
+		usages[projectId] = u
+	}
+
+	return u
+}
+
+const (
+	submissionWindow = 10 * time.Minute
+	maxSubmissions   = 5
+)
+
+type window struct {
+	count     int
+	startedAt time.Time
+}
+
+var submissions = map[string]*window{}
+
+// AllowSubmission lets a visitor send maxSubmissions forms per submissionWindow to a project
+func AllowSubmission(projectId int, ip string) bool {
+	key := strconv.Itoa(projectId) + "|" + ip
+	now := time.Now()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// expired windows are dropped once in a while so the map does not grow with every visitor
+	if len(submissions) > 10000 {
+		for key, w := range submissions {
+			if now.Sub(w.startedAt) > submissionWindow {
+				delete(submissions, key)
+			}
+		}
+	}
+
+	w, found := submissions[key]
+	if !found || now.Sub(w.startedAt) > submissionWindow {
+		w = &window{startedAt: now}
+		submissions[key] = w
+	}
+
+	if w.count >= maxSubmissions {
+		return false
+	}
+
+	w.count++
+
+	return true
+}
+
+const (
+	passwordWindow = 15 * time.Minute
+	// failed attempts a visitor gets per project, and all visitors together, before being turned away
+	maxVisitorFailures = 10
+	maxProjectFailures = 200
+	maxTrackedVisitors = 10000
+)
+
+var (
+	visitorFailures = map[string]*window{}
+	projectFailures = map[int]*window{}
+)
+
+// AllowPasswordAttempt reports whether the visitor may try the project's password, bypass token or basic credentials.
+// Only failures count, a visitor who keeps getting it right is never held back
+func AllowPasswordAttempt(projectId int, ip string) bool {
+	now := time.Now()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if w, found := projectFailures[projectId]; found && now.Sub(w.startedAt) <= passwordWindow && w.count >= maxProjectFailures {
+		return false
+	}
+
+	w, found := visitorFailures[strconv.Itoa(projectId)+"|"+ip]
+
+	return !found || now.Sub(w.startedAt) > passwordWindow || w.count < maxVisitorFailures
+}
+
+// FailedPasswordAttempt counts a wrong password, bypass token or basic credentials against the visitor and the project
+func FailedPasswordAttempt(projectId int, ip string) {
+	key := strconv.Itoa(projectId) + "|" + ip
+	now := time.Now()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// expired windows are dropped first, if every visitor is still within its window random ones make room
+	if _, found := visitorFailures[key]; !found && len(visitorFailures) >= maxTrackedVisitors {
+		for key, w := range visitorFailures {
+			if now.Sub(w.startedAt) > passwordWindow {
+				delete(visitorFailures, key)
+			}
+		}
+		for key := range visitorFailures {
+			if len(visitorFailures) < maxTrackedVisitors {
+				break
+			}
+			delete(visitorFailures, key)
+		}
+	}
+
+	countFailure(visitorFailures, key, now)
+	countFailure(projectFailures, projectId, now)
+}
+
+// countFailure adds a failure to the key's window, the caller holds the mutex
+func countFailure[K comparable](windows map[K]*window, key K, now time.Time) {
+	w, found := windows[key]
+	if !found || now.Sub(w.startedAt) > passwordWindow {
+		w = &window{startedAt: now}
+		windows[key] = w
+	}
+
+	w.count++
+}
@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"staticServer/analytics"
 	"staticServer/config"
+	"staticServer/functions"
+	"staticServer/limits"
 	prom "staticServer/prometheus"
+	"staticServer/site"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/adaptor"
@@ -24,88 +29,117 @@ import (
 
 var IsOnProd bool
 
-func init() {
+// initServer connects to the database and object storage and starts the background jobs, it runs from main
+// rather than init so the package's tests do not need either
+func initServer() {
 	parseFlags()
 
 	if !IsOnProd {
 		initGoDotENV()
 	}
 
+	config.InitDBConnection()
 	config.InitMinioConnection()
+	site.InitAccessSecret()
+	analytics.Start()
+	limits.Start()
+	functions.Init()
+
+	prometheus.MustRegister(prom.DeploymentRequestCounter)
+	prometheus.MustRegister(prom.RequestDuration)
+	prometheus.MustRegister(prom.ResponseSize)
+	prometheus.MustRegister(prom.StorageFetchDuration)
+	prometheus.MustRegister(prom.CacheRequestCounter)
+}
+
+var errFileNotFound = errors.New("[BUCKET] file was not found")
+
+type storedFile struct {
+	Name        string
+	Content     []byte
+	ContentType string
+}
 
-	prometheus.MustRegister(prom.FileRequestCounter)
+// getFile returns errFileNotFound when the object does not exist, any other error means the storage is unhealthy
+func getFile(fileName string) (*storedFile, error) {
+	startedAt := time.Now()
+
+	file, err := readObject(fileName)
+	observeStorage("get", startedAt, err)
+
+	return file, err
 }
 
-func getFile(fileName string) ([]byte, error) {
+func readObject(fileName string) (*storedFile, error) {
 	bucket, bucketExists := os.LookupEnv("MIO_BUCKET")
 	if !bucketExists {
-		return []byte(""), fmt.Errorf("[BUCKET] bucket name was not found in env")
+		return nil, fmt.Errorf("[BUCKET] bucket name was not found in env")
 	}
 
 	object, getErr := config.Minio.GetObject(context.Background(), bucket, fileName, minio.GetObjectOptions{})
 	if getErr != nil {
 		fmt.Println(getErr)
-		return []byte(""), getErr
+		return nil, getErr
 	}
 
 	defer object.Close()
 
+	info, statErr := object.Stat()
+	if statErr != nil {
+		if minio.ToErrorResponse(statErr).Code == "NoSuchKey" {
+			return nil, errFileNotFound
+		}
+
+		return nil, statErr
+	}
+
 	content, readErr := io.ReadAll(object)
 	if readErr != nil {
-		return []byte(""), readErr
+		return nil, readErr
 	}
 
-	return content, nil
+	return &storedFile{Name: fileName, Content: content, ContentType: info.ContentType}, nil
 }
 
-func main() {
-	// Initialize a new Fiber app
-	app := fiber.New()
+func fileExists(fileName string) (bool, error) {
+	startedAt := time.Now()
 
-	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	exists, err := statObject(fileName)
+	observeStorage("stat", startedAt, err)
 
-	// MIME type map
-	mimeTypes := map[string]string{
-		".html": "text/html",
-		".js":   "application/javascript",
-		".css":  "text/css",
-		".json": "application/json",
-		".png":  "image/png",
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".gif":  "image/gif",
-		".svg":  "image/svg+xml",
-	}
+	return exists, err
+}
 
-	// Route to handle all GET requests
-	app.Get("*", func(c fiber.Ctx) error {
-		projectName := strings.Split(c.Hostname(), ".")[0]
-		path := c.Path()
+func statObject(fileName string) (bool, error) {
+	bucket, bucketExists := os.LookupEnv("MIO_BUCKET")
+	if !bucketExists {
+		return false, fmt.Errorf("[BUCKET] bucket name was not found in env")
+	}
 
-		// Determine the file name
-		fileName := projectName + path
-		if path == "/" || !strings.Contains(path, ".") {
-			fileName = projectName + "/index.html"
+	_, statErr := config.Minio.StatObject(context.Background(), bucket, fileName, minio.StatObjectOptions{})
+	if statErr != nil {
+		if minio.ToErrorResponse(statErr).Code == "NoSuchKey" {
+			return false, nil
 		}
 
-		// Get the file content
-		file, err := getFile(fileName)
-		if err != nil {
-			return c.Status(fiber.StatusNotFound).SendFile("./public/404.html")
-		}
+		return false, statErr
+	}
 
-		prom.FileRequestCounter.With(prometheus.Labels{"site": projectName, "file": fileName}).Inc()
+	return true, nil
+}
 
-		// Set the content type based on the file extension
-		ext := filepath.Ext(fileName)
-		if mimeType, found := mimeTypes[ext]; found {
-			c.Set("Content-Type", mimeType)
-		} else {
-			c.Type("text")
-		}
+func main() {
+	initServer()
+
+	// Initialize a new Fiber app
+	app := fiber.New()
+
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
+	app.Post(loginPath, login)
 
-		return c.Send(file)
-	})
+	// besides GET, functions take any method and paths marked as forms at build time accept a POST
+	app.All("*", serveSite)
 
 	// HTTP/2 server setup
 	http2Server := &http2.Server{}
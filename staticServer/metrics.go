@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	prom "staticServer/prometheus"
+	"staticServer/site"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// observeRequest records the response per site and per variant, the latter lets the canary's
+// error rate be compared with the active one
+func observeRequest(c fiber.Ctx, activeSite *site.Site, variant string, err error, startedAt time.Time) {
+	statusClass := strconv.Itoa(responseStatus(c, err)/100) + "xx"
+
+	prom.DeploymentRequestCounter.With(prometheus.Labels{
+		"site":         activeSite.Name,
+		"variant":      variant,
+		"status_class": statusClass,
+	}).Inc()
+
+	labels := prometheus.Labels{"site": activeSite.Name, "status_class": statusClass}
+
+	prom.RequestDuration.With(labels).Observe(time.Since(startedAt).Seconds())
+	prom.ResponseSize.With(labels).Observe(float64(len(c.Response().Body())))
+}
+
+// deploymentVariant names the deployment a request was served from without its build id, every build
+// would otherwise start a new series that is never removed
+func deploymentVariant(activeSite *site.Site, chosenSite *site.Site) string {
+	switch {
+	case activeSite.Pinned:
+		return "pinned"
+	case chosenSite != activeSite:
+		return "canary"
+	default:
+		return "active"
+	}
+}
+
+// observeStorage records how long an object storage call took, a missing object is not a failure
+func observeStorage(operation string, startedAt time.Time, err error) {
+	result := "ok"
+	if err == errFileNotFound {
+		result = "not_found"
+	} else if err != nil {
+		result = "error"
+	}
+
+	prom.StorageFetchDuration.With(prometheus.Labels{"operation": operation, "result": result}).Observe(time.Since(startedAt).Seconds())
+}
+
+// responseStatus is the status the visitor receives, errors are turned into a response by fiber after the handler returned
+func responseStatus(c fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,65 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Dialer only connects to public addresses. The check runs on the address that is about to be dialed,
+// after DNS has been resolved, so a name that resolves to a public address once and an internal one later
+// can not slip through
+var Dialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		if !IsPublic(net.ParseIP(host)) {
+			return fmt.Errorf("[OUTBOUND] %s is not a public address", host)
+		}
+
+		return nil
+	},
+}
+
+// IsPublic reports whether the address is reachable on the internet, loopback, private, link local
+// (cloud metadata lives there), multicast and unspecified addresses are not
+func IsPublic(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// HTTPClient is an http.Client that only reaches public addresses, redirects are dialed through the same check
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         Dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// ProxyClient is the fasthttp client external rewrites are proxied through
+var ProxyClient = &fasthttp.Client{
+	NoDefaultUserAgentHeader: true,
+	DisablePathNormalizing:   true,
+	ReadTimeout:              30 * time.Second,
+	WriteTimeout:             30 * time.Second,
+	Dial: func(addr string) (net.Conn, error) {
+		return Dialer.DialContext(context.Background(), "tcp", addr)
+	},
+}
@@ -4,9 +4,42 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var FileRequestCounter = prometheus.NewCounterVec(
+// every label below has a bounded set of values, per file numbers live in the analytics rollups
+
+var DeploymentRequestCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "deployment_requests_total",
+		Help: "Total number of responses per site, variant (active, canary or pinned) and status class",
+	}, []string{"site", "variant", "status_class"},
+)
+
+var RequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "request_duration_seconds",
+		Help:    "Time taken to answer a request per site and status class",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"site", "status_class"},
+)
+
+var ResponseSize = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "response_size_bytes",
+		Help:    "Size of response bodies per site and status class",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 9),
+	}, []string{"site", "status_class"},
+)
+
+var StorageFetchDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "storage_fetch_duration_seconds",
+		Help:    "Time taken by object storage calls per operation and result",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation", "result"},
+)
+
+var CacheRequestCounter = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
-		Name: "file_requests_total",
-		Help: "Total number of requests per file",
-	}, []string{"site", "file"},
+		Name: "cache_requests_total",
+		Help: "Total number of cache lookups per cache and result, the hit ratio is hit over all",
+	}, []string{"cache", "result"},
 )
@@ -0,0 +1,180 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"html/template"
+	"staticServer/limits"
+	"staticServer/site"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	accessCookieName = "__deployio_access"
+	bypassHeader     = "X-Deployio-Bypass"
+	bypassQuery      = "__deployio_bypass"
+	loginPath        = "/__deployio/login"
+	accessDuration   = 7 * 24 * time.Hour
+)
+
+//go:embed public/login.html
+var loginPageSource string
+
+var loginPage = template.Must(template.New("login").Parse(loginPageSource))
+
+// protect enforces the project's access protection, when it returns false the response was already written
+func protect(c fiber.Ctx, activeSite *site.Site) (bool, error) {
+	if !activeSite.IsProtected() {
+		return true, nil
+	}
+
+	if activeSite.VerifyAccessCookie(c.Cookies(accessCookieName)) {
+		return true, nil
+	}
+
+	token := bypassToken(c)
+	_, password, hasCredentials := basicCredentials(c)
+	hasCredentials = hasCredentials && activeSite.Protection == site.ProtectionBasic
+
+	// guessing is throttled per visitor and per project before any token or password is checked
+	if (len(token) > 0 || hasCredentials) && !limits.AllowPasswordAttempt(activeSite.ProjectId, c.IP()) {
+		return false, tooManyAttempts(c)
+	}
+
+	if len(token) > 0 {
+		valid, err := activeSite.IsBypassToken(token)
+		if err != nil {
+			return false, unavailable(c, err)
+		}
+
+		if valid {
+			// a token in the url is only there once, the cookie lets the page load its assets
+			if len(c.Query(bypassQuery)) > 0 {
+				setAccessCookie(c, activeSite)
+			}
+			return true, nil
+		}
+
+		limits.FailedPasswordAttempt(activeSite.ProjectId, c.IP())
+	}
+
+	if activeSite.Protection == site.ProtectionBasic {
+		if hasCredentials {
+			if activeSite.VerifyPassword(password) {
+				return true, nil
+			}
+
+			limits.FailedPasswordAttempt(activeSite.ProjectId, c.IP())
+		}
+
+		c.Set("WWW-Authenticate", `Basic realm="`+activeSite.Name+`", charset="UTF-8"`)
+		return false, c.Status(fiber.StatusUnauthorized).SendString("Authentication required")
+	}
+
+	return false, renderLogin(c, activeSite, c.OriginalURL(), false)
+}
+
+// login handles the form of the login page for sites protected with ProtectionLogin
+func login(c fiber.Ctx) error {
+	activeSite, siteErr := site.FromHost(c.Hostname())
+	if siteErr != nil {
+		if siteErr == site.ErrNotFound {
+			return notFound(c, nil)
+		}
+		return unavailable(c, siteErr)
+	}
+
+	redirect := c.FormValue("redirect")
+
+	// only local paths, `//host` would send the visitor to another site
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		redirect = "/"
+	}
+
+	if activeSite.Protection != site.ProtectionLogin {
+		return c.Redirect().Status(fiber.StatusSeeOther).To(redirect)
+	}
+
+	// the login form is routed outside serveDeployment, so the project's request rate is applied here as well
+	if !limits.Allow(activeSite.ProjectId, activeSite.RateLimit, activeSite.RateBurst) {
+		c.Set("Retry-After", "1")
+		c.Set("Cache-Control", "no-store")
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many requests")
+	}
+
+	if !limits.AllowPasswordAttempt(activeSite.ProjectId, c.IP()) {
+		return tooManyAttempts(c)
+	}
+
+	if !activeSite.VerifyPassword(c.FormValue("password")) {
+		limits.FailedPasswordAttempt(activeSite.ProjectId, c.IP())
+		return renderLogin(c, activeSite, redirect, true)
+	}
+
+	setAccessCookie(c, activeSite)
+
+	return c.Redirect().Status(fiber.StatusSeeOther).To(redirect)
+}
+
+func renderLogin(c fiber.Ctx, activeSite *site.Site, redirect string, failed bool) error {
+	var page strings.Builder
+
+	err := loginPage.Execute(&page, map[string]any{
+		"Action":   loginPath,
+		"Site":     activeSite.Name,
+		"Redirect": redirect,
+		"Failed":   failed,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusUnauthorized).SendString(page.String())
+}
+
+func tooManyAttempts(c fiber.Ctx) error {
+	c.Set("Cache-Control", "no-store")
+	c.Set("Retry-After", "900")
+	return c.Status(fiber.StatusTooManyRequests).SendString("Too many attempts, try again later")
+}
+
+func setAccessCookie(c fiber.Ctx, activeSite *site.Site) {
+	expiresAt := time.Now().Add(accessDuration)
+
+	c.Cookie(&fiber.Cookie{
+		Name:     accessCookieName,
+		Value:    activeSite.AccessCookie(expiresAt),
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   c.Secure(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func bypassToken(c fiber.Ctx) string {
+	if token := c.Get(bypassHeader); len(token) > 0 {
+		return token
+	}
+
+	return c.Query(bypassQuery)
+}
+
+func basicCredentials(c fiber.Ctx) (string, string, bool) {
+	encoded, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Basic ")
+	if !found {
+		return "", "", false
+	}
+
+	decoded, decodeErr := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if decodeErr != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"mime"
+	"path"
+	"path/filepath"
+	"staticServer/analytics"
+	"staticServer/limits"
+	"staticServer/outbound"
+	"staticServer/site"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/proxy"
+)
+
+//go:embed public/404.html
+var notFoundPage []byte
+
+//go:embed public/5xx.html
+var unavailablePage []byte
+
+//go:embed public/quota.html
+var quotaPage []byte
+
+//go:embed public/maintenance.html
+var maintenancePage []byte
+
+func serveSite(c fiber.Ctx) error {
+	startedAt := time.Now()
+
+	activeSite, siteErr := site.FromHost(c.Hostname())
+	if siteErr != nil {
+		if siteErr == site.ErrNotFound {
+			return renamedOrNotFound(c)
+		}
+		return unavailable(c, siteErr)
+	}
+
+	chosenSite := pickDeployment(c, activeSite)
+	variant := deploymentVariant(activeSite, chosenSite)
+	activeSite = chosenSite
+
+	err := serveDeployment(c, activeSite)
+	observeRequest(c, activeSite, variant, err, startedAt)
+	recordEvent(c, activeSite, err, startedAt)
+
+	return err
+}
+
+func serveDeployment(c fiber.Ctx, activeSite *site.Site) error {
+	requestPath := cleanPath(c.Path())
+
+	if !limits.Allow(activeSite.ProjectId, activeSite.RateLimit, activeSite.RateBurst) {
+		c.Set("Retry-After", "1")
+		c.Set("Cache-Control", "no-store")
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many requests")
+	}
+
+	if now := time.Now(); activeSite.InMaintenance(now) && !activeSite.BypassesMaintenance(c.IP()) {
+		return underMaintenance(c, activeSite, now)
+	}
+
+	if limits.QuotaExceeded(activeSite.ProjectId, activeSite.BandwidthQuota) {
+		return quotaExceeded(c, activeSite)
+	}
+
+	if allowed, err := protect(c, activeSite); !allowed {
+		return err
+	}
+
+	if function, found := activeSite.Config.FunctionFor(requestPath); found {
+		return runFunction(c, activeSite, function)
+	}
+
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead:
+	case fiber.MethodPost:
+		return submitForm(c, activeSite)
+	default:
+		return c.SendStatus(fiber.StatusMethodNotAllowed)
+	}
+
+	// forced rules are applied even when a file exists at the path
+	if rule, destination, matched := activeSite.Config.Match(requestPath, true); matched {
+		return applyRule(c, activeSite, rule, destination)
+	}
+
+	file, canonicalPath, resolveErr := resolve(activeSite.Prefix, requestPath)
+	if resolveErr != nil {
+		return unavailable(c, resolveErr)
+	}
+	if len(canonicalPath) > 0 {
+		return redirectTo(c, fiber.StatusMovedPermanently, canonicalPath)
+	}
+	if file != nil {
+		return sendFile(c, activeSite, file)
+	}
+
+	if rule, destination, matched := activeSite.Config.Match(requestPath, false); matched {
+		return applyRule(c, activeSite, rule, destination)
+	}
+
+	// only navigations fall back to the app shell, a missing asset should stay a 404
+	if activeSite.SpaFallback && !strings.Contains(path.Base(requestPath), ".") {
+		file, err := getFile(activeSite.Prefix + "/index.html")
+		if err == nil {
+			return sendFile(c, activeSite, file)
+		}
+		if err != errFileNotFound {
+			return unavailable(c, err)
+		}
+	}
+
+	return notFound(c, activeSite)
+}
+
+func recordEvent(c fiber.Ctx, activeSite *site.Site, err error, startedAt time.Time) {
+	status := responseStatus(c, err)
+
+	analytics.Record(analytics.Event{
+		ProjectId: activeSite.ProjectId,
+		Host:      c.Hostname(),
+		Path:      c.Path(),
+		Status:    status,
+		Bytes:     len(c.Response().Body()),
+		PageView:  status < 300 && strings.HasPrefix(c.GetRespHeader("Content-Type"), "text/html"),
+		Referrer:  c.Get(fiber.HeaderReferer),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Latency:   time.Since(startedAt),
+		Time:      startedAt,
+	})
+}
+
+// resolve finds the object for a request path by trying the exact file, `path.html` and `path/index.html`.
+// When the page only exists under the other trailing slash form, the canonical path is returned to redirect to.
+// A page asked for with its `.html` or `/index.html` name is returned along with its clean path, rewrites
+// serve the file and visitors are redirected.
+// A missing page is not an error, the returned error is always a storage failure.
+func resolve(prefix, requestPath string) (*storedFile, string, error) {
+	if strings.HasSuffix(requestPath, "/") {
+		file, err := getFile(prefix + requestPath + "index.html")
+		if err == nil {
+			return file, "", nil
+		}
+		if err != errFileNotFound {
+			return nil, "", err
+		}
+
+		trimmedPath := strings.TrimSuffix(requestPath, "/")
+		if len(trimmedPath) == 0 {
+			return nil, "", nil
+		}
+
+		exists, existsErr := fileExists(prefix + trimmedPath + ".html")
+		if existsErr != nil || !exists {
+			return nil, "", existsErr
+		}
+
+		return nil, trimmedPath, nil
+	}
+
+	file, err := getFile(prefix + requestPath)
+	if err == nil {
+		cleanPath, cleanErr := cleanURL(prefix, requestPath)
+		return file, cleanPath, cleanErr
+	}
+	if err != errFileNotFound {
+		return nil, "", err
+	}
+
+	file, err = getFile(prefix + requestPath + ".html")
+	if err == nil {
+		return file, "", nil
+	}
+	if err != errFileNotFound {
+		return nil, "", err
+	}
+
+	exists, existsErr := fileExists(prefix + requestPath + "/index.html")
+	if existsErr != nil || !exists {
+		return nil, "", existsErr
+	}
+
+	return nil, requestPath + "/", nil
+}
+
+// cleanURL is the path a `.html` page is served under without its extension, `/about` for `/about.html`
+// and `/docs/` for `/docs/index.html`. It is empty for other files and when a file stored under the
+// clean name itself would be served instead
+func cleanURL(prefix, requestPath string) (string, error) {
+	if path.Base(requestPath) == "index.html" {
+		return strings.TrimSuffix(requestPath, "index.html"), nil
+	}
+
+	cleanPath, isPage := strings.CutSuffix(requestPath, ".html")
+	if !isPage || strings.HasSuffix(cleanPath, "/") {
+		return "", nil
+	}
+
+	exists, err := fileExists(prefix + cleanPath)
+	if err != nil || exists {
+		return "", err
+	}
+
+	return cleanPath, nil
+}
+
+// renamedOrNotFound sends visitors of a renamed project's old subdomain to the new one,
+// 308 keeps the method so form posts and functions keep working through the redirect
+func renamedOrNotFound(c fiber.Ctx) error {
+	newHost, err := site.RenamedHost(c.Host())
+	if err != nil {
+		return unavailable(c, err)
+	}
+
+	if len(newHost) == 0 {
+		return notFound(c, nil)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.Redirect().Status(fiber.StatusPermanentRedirect).To(c.Protocol() + "://" + newHost + c.OriginalURL())
+}
+
+// notFound serves the deployment's own 404.html when it ships one, otherwise the built in page. When the
+// storage fails while looking for 404.html the request is answered as unavailable, not as missing
+func notFound(c fiber.Ctx, activeSite *site.Site) error {
+	if activeSite != nil {
+		file, err := getFile(activeSite.Prefix + "/404.html")
+		if err == nil {
+			addBandwidth(c, activeSite, len(file.Content))
+			c.Set("Cache-Control", site.RevalidateCacheControl)
+			c.Set("Content-Type", "text/html; charset=utf-8")
+			return c.Status(fiber.StatusNotFound).Send(file.Content)
+		}
+
+		if err != errFileNotFound {
+			return siteUnavailable(c, activeSite, err)
+		}
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusNotFound).Send(notFoundPage)
+}
+
+// unavailable answers with a 503 when the database or the object storage can not be reached
+func unavailable(c fiber.Ctx, err error) error {
+	fmt.Println("[SERVE] " + err.Error())
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Retry-After", "30")
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusServiceUnavailable).Send(unavailablePage)
+}
+
+// siteUnavailable is unavailable with the deployment's own 503.html when it ships one and it can still be read
+func siteUnavailable(c fiber.Ctx, activeSite *site.Site, err error) error {
+	file, fileErr := getFile(activeSite.Prefix + "/503.html")
+	if fileErr != nil {
+		return unavailable(c, err)
+	}
+
+	fmt.Println("[SERVE] " + err.Error())
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Retry-After", "30")
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusServiceUnavailable).Send(file.Content)
+}
+
+// quotaExceeded serves the project's own quota page when it configured one, otherwise the built in page
+func quotaExceeded(c fiber.Ctx, activeSite *site.Site) error {
+	page := quotaPage
+	if len(activeSite.QuotaPage) > 0 {
+		page = []byte(activeSite.QuotaPage)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusTooManyRequests).Send(page)
+}
+
+// underMaintenance serves the project's own maintenance page when it configured one, otherwise the built in page
+func underMaintenance(c fiber.Ctx, activeSite *site.Site, now time.Time) error {
+	page := maintenancePage
+	if len(activeSite.MaintenancePage) > 0 {
+		page = []byte(activeSite.MaintenancePage)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Retry-After", strconv.Itoa(activeSite.MaintenanceRetryAfter(now)))
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusServiceUnavailable).Send(page)
+}
+
+func sendFile(c fiber.Ctx, activeSite *site.Site, file *storedFile) error {
+	for name, value := range activeSite.Config.HeadersFor(c.Path()) {
+		c.Set(name, value)
+	}
+
+	// a pinned build is a copy of the site, search engines should only index the live one
+	if activeSite.Pinned {
+		c.Set("X-Robots-Tag", "noindex")
+	}
+
+	// protected pages must never end up in a shared cache
+	if activeSite.IsProtected() {
+		c.Set("Cache-Control", "private, no-store")
+	}
+
+	// a _headers rule may have set the type on purpose
+	if len(c.GetRespHeader("Content-Type")) == 0 {
+		c.Set("Content-Type", contentType(file))
+	}
+
+	if activeSite.InjectRuntimeEnv && strings.HasPrefix(c.GetRespHeader("Content-Type"), "text/html") {
+		script, scriptErr := activeSite.RuntimeScript()
+		if scriptErr != nil {
+			return unavailable(c, scriptErr)
+		}
+
+		content := injectScript(file.Content, script)
+		addBandwidth(c, activeSite, len(content))
+		return c.Send(content)
+	}
+
+	addBandwidth(c, activeSite, len(file.Content))
+	return c.Send(file.Content)
+}
+
+// addBandwidth counts a file of the deployment against the project's quota. Pages the server answers with
+// itself, rate limit, quota, maintenance or error pages, are not the project's traffic and are not counted
+func addBandwidth(c fiber.Ctx, activeSite *site.Site, bytes int) {
+	if c.Method() == fiber.MethodHead {
+		return
+	}
+
+	limits.AddBandwidth(activeSite.ProjectId, bytes)
+}
+
+// injectScript places the script right before </head> so it runs ahead of the page's own scripts,
+// pages without a head get it at the very start
+func injectScript(page []byte, script []byte) []byte {
+	index := bytes.Index(bytes.ToLower(page), []byte("</head>"))
+	if index == -1 {
+		index = 0
+	}
+
+	injected := make([]byte, 0, len(page)+len(script))
+	injected = append(injected, page[:index]...)
+	injected = append(injected, script...)
+	injected = append(injected, page[index:]...)
+
+	return injected
+}
+
+// contentType trusts the type the build server stored with the object, its table is the only one
+// deciding types. Files uploaded before types were detected at build time all carry
+// application/octet-stream and get the standard library's guess until the project is deployed again.
+func contentType(file *storedFile) string {
+	if len(file.ContentType) > 0 && file.ContentType != "application/octet-stream" {
+		return file.ContentType
+	}
+
+	if detected := mime.TypeByExtension(filepath.Ext(file.Name)); len(detected) > 0 {
+		return detected
+	}
+
+	return "application/octet-stream"
+}
+
+// applyRule redirects, proxies or rewrites the request according to a rule from the deployment's site config
+func applyRule(c fiber.Ctx, activeSite *site.Site, rule *site.Rule, destination string) error {
+	if rule.IsExternal() && !rule.KeepsOrigin(destination) {
+		return notFound(c, activeSite)
+	}
+
+	if rule.Status != fiber.StatusOK {
+		return redirectTo(c, rule.Status, destination)
+	}
+
+	// the proxy client refuses internal addresses, a site can not reach our own network through a rewrite
+	if rule.IsExternal() {
+		return proxy.Do(c, withQuery(c, destination), outbound.ProxyClient)
+	}
+
+	file, _, resolveErr := resolve(activeSite.Prefix, cleanPath(destination))
+	if resolveErr != nil {
+		return unavailable(c, resolveErr)
+	}
+	if file == nil {
+		return notFound(c, activeSite)
+	}
+
+	return sendFile(c, activeSite, file)
+}
+
+func redirectTo(c fiber.Ctx, status int, location string) error {
+	return c.Redirect().Status(status).To(withQuery(c, location))
+}
+
+// withQuery carries the request's query string over unless the location brings its own
+func withQuery(c fiber.Ctx, location string) string {
+	query := string(c.Request().URI().QueryString())
+
+	if len(query) > 0 && !strings.Contains(location, "?") {
+		return location + "?" + query
+	}
+
+	return location
+}
+
+// cleanPath removes `..` and duplicate slashes while keeping the trailing slash that decides how a path resolves
+func cleanPath(requestPath string) string {
+	cleaned := path.Clean("/" + requestPath)
+
+	if strings.HasSuffix(requestPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
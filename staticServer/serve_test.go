@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"staticServer/config"
+	"staticServer/site"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const bucket = "deployments"
+
+// objects of the deployment every test serves, stored under the prefix site/1
+var objects = map[string]string{
+	"index.html":      "home",
+	"about.html":      "about",
+	"docs/index.html": "docs",
+	"docs/setup.html": "setup",
+	"app.js":          "script",
+	// a file without an extension next to the page of the same name
+	"notes":      "plain notes",
+	"notes.html": "notes page",
+}
+
+// objects the storage fails to read
+var broken = map[string]bool{"site/1/broken": true}
+
+func TestMain(m *testing.M) {
+	storage := httptest.NewServer(http.HandlerFunc(serveObject))
+	defer storage.Close()
+
+	client, err := minio.New(strings.TrimPrefix(storage.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("key", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	config.Minio = client
+	os.Setenv("MIO_BUCKET", bucket)
+
+	os.Exit(m.Run())
+}
+
+// serveObject answers GET and HEAD of the s3 api for the objects
+func serveObject(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")
+
+	// refused rather than failed, the client retries server errors for seconds
+	if broken[key] {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `<Error><Code>AccessDenied</Code><Message>denied</Message><Key>%s</Key></Error>`, key)
+		}
+		return
+	}
+
+	content, found := objects[strings.TrimPrefix(key, "site/1/")]
+	if !found || !strings.HasPrefix(key, "site/1/") {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message><Key>%s</Key></Error>`, key)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+	w.Header().Set("ETag", `"etag"`)
+
+	if r.Method == http.MethodGet {
+		io.WriteString(w, content)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		content   string
+		canonical string
+	}{
+		{"root", "/", "home", ""},
+		{"clean url", "/about", "about", ""},
+		{"directory index", "/docs/", "docs", ""},
+		{"page in a directory", "/docs/setup", "setup", ""},
+		{"exact file", "/app.js", "script", ""},
+		{"page without trailing slash", "/about/", "", "/about"},
+		{"directory without trailing slash", "/docs", "", "/docs/"},
+		{"html extension", "/about.html", "about", "/about"},
+		{"html extension in a directory", "/docs/setup.html", "setup", "/docs/setup"},
+		{"index.html", "/docs/index.html", "docs", "/docs/"},
+		{"root index.html", "/index.html", "home", "/"},
+		{"exact file wins over the page", "/notes", "plain notes", ""},
+		{"page shadowed by a file of its clean name", "/notes.html", "notes page", ""},
+		{"missing page", "/missing", "", ""},
+		{"missing directory", "/missing/", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, canonical, err := resolve("site/1", test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content := ""
+			if file != nil {
+				content = string(file.Content)
+			}
+
+			if content != test.content {
+				t.Errorf("content = %q, want %q", content, test.content)
+			}
+			if canonical != test.canonical {
+				t.Errorf("canonical path = %q, want %q", canonical, test.canonical)
+			}
+		})
+	}
+}
+
+func TestResolveStorageFailure(t *testing.T) {
+	if _, _, err := resolve("site/1", "/broken"); err == nil {
+		t.Errorf("a storage failure was taken for a missing page")
+	}
+}
+
+func TestServeDeployment(t *testing.T) {
+	tests := []struct {
+		name        string
+		spaFallback bool
+		path        string
+		status      int
+		body        string
+		location    string
+	}{
+		{"page", false, "/about", http.StatusOK, "about", ""},
+		{"directory index", false, "/docs/", http.StatusOK, "docs", ""},
+		{"html extension redirects", false, "/about.html?ref=nav", http.StatusMovedPermanently, "", "/about?ref=nav"},
+		{"index.html redirects", false, "/docs/index.html", http.StatusMovedPermanently, "", "/docs/"},
+		{"trailing slash redirects", false, "/docs", http.StatusMovedPermanently, "", "/docs/"},
+		{"unknown route without fallback", false, "/dashboard/settings", http.StatusNotFound, "", ""},
+		{"unknown route falls back to the app", true, "/dashboard/settings", http.StatusOK, "home", ""},
+		{"pages resolve before the fallback", true, "/about", http.StatusOK, "about", ""},
+		{"directories redirect before the fallback", true, "/docs", http.StatusMovedPermanently, "", "/docs/"},
+		{"missing asset is not sent the app", true, "/missing.js", http.StatusNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			activeSite := &site.Site{ProjectId: 1, Name: "site", Prefix: "site/1", SpaFallback: test.spaFallback}
+
+			app := fiber.New()
+			app.All("*", func(c fiber.Ctx) error { return serveDeployment(c, activeSite) })
+
+			response, err := app.Test(httptest.NewRequest("GET", test.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			body, _ := io.ReadAll(response.Body)
+
+			if response.StatusCode != test.status {
+				t.Fatalf("status = %d, want %d", response.StatusCode, test.status)
+			}
+			if len(test.body) > 0 && string(body) != test.body {
+				t.Errorf("body = %q, want %q", body, test.body)
+			}
+			if location := response.Header.Get("Location"); location != test.location {
+				t.Errorf("location = %q, want %q", location, test.location)
+			}
+		})
+	}
+}
+
+func TestRewriteServesHtmlName(t *testing.T) {
+	activeSite := &site.Site{ProjectId: 1, Name: "site", Prefix: "site/1", Config: site.Config{
+		Redirects: []site.Rule{{From: "/app/*", To: "/index.html", Status: http.StatusOK}},
+	}}
+
+	app := fiber.New()
+	app.All("*", func(c fiber.Ctx) error { return serveDeployment(c, activeSite) })
+
+	response, err := app.Test(httptest.NewRequest("GET", "/app/projects", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	if response.StatusCode != http.StatusOK || string(body) != "home" {
+		t.Errorf("status = %d, body = %q, want the rewrite to serve index.html", response.StatusCode, body)
+	}
+}
@@ -0,0 +1,127 @@
+package site
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"staticServer/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ProtectionNone  = "none"
+	ProtectionBasic = "basic"
+	ProtectionLogin = "login"
+)
+
+var accessSecret []byte
+
+// a password hash changes when the password does, so an accepted password can be remembered for long
+const passwordTTL = time.Hour
+
+// bcrypt is slow on purpose, so a password that was accepted once is remembered per site
+var verifiedPasswords = newTTLCache[[32]byte, bool](maxCachedSites)
+
+// only tokens that were found are remembered, a made up one costs a query and a failed attempt instead
+var bypassTokens = newTTLCache[string, bool](maxCachedSites)
+
+func InitAccessSecret() {
+	secret, secretExists := os.LookupEnv("SITE_ACCESS_SECRET")
+	if !secretExists || len(strings.TrimSpace(secret)) < 32 {
+		log.Fatalln("[SITE] SITE_ACCESS_SECRET must be at least 32 characters long.")
+	}
+
+	accessSecret = []byte(secret)
+}
+
+func (s *Site) IsProtected() bool {
+	return s.Protection == ProtectionBasic || s.Protection == ProtectionLogin
+}
+
+func (s *Site) VerifyPassword(password string) bool {
+	if len(s.PasswordHash) == 0 {
+		return false
+	}
+
+	key := sha256.Sum256([]byte(s.PasswordHash + "\x00" + password))
+	_, found := verifiedPasswords.Get(key)
+	countCache("password", found)
+	if found {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(s.PasswordHash), []byte(password)) != nil {
+		return false
+	}
+
+	verifiedPasswords.Set(key, true, passwordTTL)
+
+	return true
+}
+
+// AccessCookie returns a cookie value proving the visitor passed the protection until expiresAt.
+// The password hash is part of the signature, so changing the password logs every visitor out.
+func (s *Site) AccessCookie(expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return expiry + "." + s.sign(expiry)
+}
+
+func (s *Site) VerifyAccessCookie(value string) bool {
+	expiry, signature, found := strings.Cut(value, ".")
+	if !found {
+		return false
+	}
+
+	expiresAt, convErr := strconv.ParseInt(expiry, 10, 64)
+	if convErr != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(s.sign(expiry)))
+}
+
+func (s *Site) sign(expiry string) string {
+	mac := hmac.New(sha256.New, accessSecret)
+	mac.Write([]byte(fmt.Sprintf("%d|%s|%s", s.ProjectId, expiry, s.PasswordHash)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsBypassToken checks a token created through the project's protection settings
+func (s *Site) IsBypassToken(token string) (bool, error) {
+	tokenHash := sha256.Sum256([]byte(token))
+	key := fmt.Sprintf("%d:%x", s.ProjectId, tokenHash)
+
+	_, fresh := bypassTokens.Get(key)
+	countCache("bypass_token", fresh)
+	if fresh {
+		return true, nil
+	}
+
+	var valid bool
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM "deploy-io".bypass_tokens t
+			WHERE t.project_id = $1 AND t.token_hash = $2 AND (t.expires_at IS NULL OR t.expires_at > NOW())
+		);
+	`
+	err := config.DataBase.QueryRow(query, s.ProjectId, hex.EncodeToString(tokenHash[:])).Scan(&valid)
+	if err != nil {
+		return false, err
+	}
+
+	if valid {
+		bypassTokens.Set(key, true, cacheTTL)
+	}
+
+	return valid, nil
+}
@@ -0,0 +1,64 @@
+package site
+
+import (
+	"database/sql"
+	"staticServer/config"
+	"strings"
+)
+
+// the old name a host asked for mapped to the project's current one, empty when the name was never renamed.
+// Hosts come from requests, so misses are cached and bounded like the site cache
+var aliases = newTTLCache[string, string](maxCachedSites)
+
+// RenamedHost returns the host to redirect to when the project named by the host was renamed
+// and the old name is still within its grace period, otherwise it returns an empty string
+func RenamedHost(host string) (string, error) {
+	label := siteLabel(host)
+
+	oldName, suffix := label, ""
+	newName, err := renamedTo(label)
+	if err != nil {
+		return "", err
+	}
+
+	// pinned builds of the old name move along with it
+	if len(newName) == 0 {
+		parts := pinnedLabelRegex.FindStringSubmatch(label)
+		if parts == nil {
+			return "", nil
+		}
+
+		oldName, suffix = parts[1], "-"+parts[2]
+		if newName, err = renamedTo(oldName); err != nil || len(newName) == 0 {
+			return "", err
+		}
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	return newName + suffix + strings.TrimPrefix(host, oldName+suffix), nil
+}
+
+func renamedTo(name string) (string, error) {
+	cached, found := aliases.Get(name)
+	countCache("alias", found)
+	if found {
+		return cached, nil
+	}
+
+	var newName string
+
+	query := `
+		SELECT p.name FROM "deploy-io".project_aliases a
+		JOIN "deploy-io".projects p ON p.id = a.project_id
+		WHERE a.name = $1 AND a.expires_at > NOW();
+	`
+	err := config.DataBase.QueryRow(query, name).Scan(&newName)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	aliases.Set(name, newName, cacheTTL)
+
+	return newName, nil
+}
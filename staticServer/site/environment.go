@@ -0,0 +1,155 @@
+package site
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"staticServer/config"
+	"sync"
+	"time"
+)
+
+var environments sync.Map
+
+var runtimeScripts sync.Map
+
+type environmentEntry struct {
+	values map[string]string
+	// keys of the variables marked as public runtime variables
+	public    map[string]bool
+	expiresAt time.Time
+}
+
+type scriptEntry struct {
+	script    []byte
+	expiresAt time.Time
+}
+
+// Environment returns the project's decrypted environment variables, they are reused for cacheTTL
+func (s *Site) Environment() (map[string]string, error) {
+	entry, err := s.environment()
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.values, nil
+}
+
+// RuntimeScript returns the `<script>` setting window.__ENV to the public runtime variables.
+// The snippet is built once per deployment and reused for cacheTTL.
+func (s *Site) RuntimeScript() ([]byte, error) {
+	key := fmt.Sprintf("%d:%d", s.ProjectId, s.BuildId)
+
+	cached, found := runtimeScripts.Load(key)
+	fresh := found && time.Now().Before(cached.(scriptEntry).expiresAt)
+	countCache("runtime_script", fresh)
+	if fresh {
+		return cached.(scriptEntry).script, nil
+	}
+
+	entry, err := s.environment()
+	if err != nil {
+		return nil, err
+	}
+
+	public := map[string]string{}
+	for name := range entry.public {
+		public[name] = entry.values[name]
+	}
+
+	// json.Marshal escapes <, > and &, so a value can never close the script tag
+	encoded, marshalErr := json.Marshal(public)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	script := []byte("<script>window.__ENV = " + string(encoded) + ";</script>")
+
+	runtimeScripts.Store(key, scriptEntry{script: script, expiresAt: time.Now().Add(cacheTTL)})
+
+	return script, nil
+}
+
+func (s *Site) environment() (*environmentEntry, error) {
+	entry, found := environments.Load(s.ProjectId)
+	fresh := found && time.Now().Before(entry.(*environmentEntry).expiresAt)
+	countCache("environment", fresh)
+	if fresh {
+		return entry.(*environmentEntry), nil
+	}
+
+	query := `SELECT key, value, is_public_runtime FROM "deploy-io".environments WHERE project_id = $1`
+
+	rows, queryErr := config.DataBase.Query(query, s.ProjectId)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	defer rows.Close()
+
+	values := map[string]string{}
+	public := map[string]bool{}
+
+	for rows.Next() {
+		var key, encValue string
+		var isPublic bool
+
+		if err := rows.Scan(&key, &encValue, &isPublic); err != nil {
+			return nil, err
+		}
+
+		if isPublic {
+			public[key] = true
+		}
+
+		value, decErr := decrypt(encValue)
+		if decErr != nil {
+			return nil, decErr
+		}
+
+		values[key] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	loaded := &environmentEntry{values: values, public: public, expiresAt: time.Now().Add(cacheTTL)}
+	environments.Store(s.ProjectId, loaded)
+
+	return loaded, nil
+}
+
+// decrypt reverses the encryption of the http server, the key is the same ENV_SECRET
+func decrypt(cipherText string) (string, error) {
+	key, keyExists := os.LookupEnv("ENV_SECRET")
+	if !keyExists {
+		return "", fmt.Errorf("[ENC] env secret is not accessible")
+	}
+
+	cipherBytes, err := hex.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", err
+	}
+
+	// the iv is stored in front of the cipher text
+	if len(cipherBytes) < aes.BlockSize {
+		return "", errors.New("cipherText too short")
+	}
+	iv := cipherBytes[:aes.BlockSize]
+	cipherBytes = cipherBytes[aes.BlockSize:]
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(cipherBytes, cipherBytes)
+
+	return string(cipherBytes), nil
+}
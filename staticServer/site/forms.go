@@ -0,0 +1,25 @@
+package site
+
+import "strings"
+
+// name of the hidden field that has to stay empty, the same one the build server leaves out of Fields
+const HoneypotField = "deployio-honeypot"
+
+type Form struct {
+	Name     string   `json:"name"`
+	Action   string   `json:"action"`
+	Page     string   `json:"page"`
+	Redirect string   `json:"redirect,omitempty"`
+	Fields   []string `json:"fields"`
+}
+
+// FormFor returns the form posting to the path, `/contact` and `/contact/` are the same form
+func (c Config) FormFor(path string) (*Form, bool) {
+	for index := range c.Forms {
+		if strings.TrimSuffix(c.Forms[index].Action, "/") == strings.TrimSuffix(path, "/") {
+			return &c.Forms[index], true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,52 @@
+package site
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	ImmutableCacheControl   = "public, max-age=31536000, immutable"
+	RevalidateCacheControl  = "public, max-age=0, must-revalidate"
+	fingerprintedAssetsPath = "/_next/static/"
+)
+
+// matches build tool hashes such as `index-BvL2qZ3c.js` (vite) or `main.3f9a1c2b.css` (webpack)
+var fingerprintRegex = regexp.MustCompile(`[.-]([A-Za-z0-9_-]{8,})\.(js|mjs|css|woff2?|ttf|otf|eot|png|jpe?g|gif|svg|webp|avif|ico|wasm|map)$`)
+
+// HeadersFor returns the response headers for a request path, starting from the default
+// caching policy and then applying every matching rule in the order they were declared
+func (c Config) HeadersFor(requestPath string) map[string]string {
+	headers := map[string]string{
+		"Cache-Control": RevalidateCacheControl,
+	}
+
+	if IsFingerprinted(requestPath) {
+		headers["Cache-Control"] = ImmutableCacheControl
+	}
+
+	for _, rule := range c.Headers {
+		if _, matched := match(rule.For, requestPath); !matched {
+			continue
+		}
+
+		for name, value := range rule.Values {
+			headers[name] = value
+		}
+	}
+
+	return headers
+}
+
+// IsFingerprinted reports whether the file name carries a content hash, so it can be cached forever
+func IsFingerprinted(requestPath string) bool {
+	if strings.HasPrefix(requestPath, fingerprintedAssetsPath) {
+		return true
+	}
+
+	groups := fingerprintRegex.FindStringSubmatch(path.Base(requestPath))
+
+	// plain words like `-component` also pass the length check, hashes nearly always carry a digit
+	return len(groups) > 0 && strings.ContainsAny(groups[1], "0123456789")
+}
@@ -0,0 +1,107 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadersFor(t *testing.T) {
+	config := Config{Headers: []HeaderRule{
+		{For: "/*", Values: map[string]string{"X-Frame-Options": "DENY"}},
+		{For: "/assets/*", Values: map[string]string{"Access-Control-Allow-Origin": "*"}},
+		{For: "/embed/:id", Values: map[string]string{"X-Frame-Options": "SAMEORIGIN"}},
+		{For: "/feed.xml", Values: map[string]string{"Cache-Control": "public, max-age=600", "Content-Type": "application/rss+xml"}},
+	}}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+	}{
+		{
+			name:    "page",
+			path:    "/about",
+			headers: map[string]string{"Cache-Control": RevalidateCacheControl, "X-Frame-Options": "DENY"},
+		},
+		{
+			name: "fingerprinted asset",
+			path: "/assets/index-BvL2qZ3c.js",
+			headers: map[string]string{
+				"Cache-Control":               ImmutableCacheControl,
+				"X-Frame-Options":             "DENY",
+				"Access-Control-Allow-Origin": "*",
+			},
+		},
+		{
+			name: "asset without a hash",
+			path: "/assets/logo.svg",
+			headers: map[string]string{
+				"Cache-Control":               RevalidateCacheControl,
+				"X-Frame-Options":             "DENY",
+				"Access-Control-Allow-Origin": "*",
+			},
+		},
+		{
+			name:    "later rule overrides an earlier one",
+			path:    "/embed/42",
+			headers: map[string]string{"Cache-Control": RevalidateCacheControl, "X-Frame-Options": "SAMEORIGIN"},
+		},
+		{
+			name:    "placeholder needs its segment",
+			path:    "/embed",
+			headers: map[string]string{"Cache-Control": RevalidateCacheControl, "X-Frame-Options": "DENY"},
+		},
+		{
+			name: "rule overrides the cache policy",
+			path: "/feed.xml",
+			headers: map[string]string{
+				"Cache-Control":   "public, max-age=600",
+				"Content-Type":    "application/rss+xml",
+				"X-Frame-Options": "DENY",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if headers := config.HeadersFor(test.path); !reflect.DeepEqual(headers, test.headers) {
+				t.Errorf("headers = %v\nwant %v", headers, test.headers)
+			}
+		})
+	}
+}
+
+func TestHeadersForWithoutRules(t *testing.T) {
+	headers := Config{}.HeadersFor("/")
+
+	if !reflect.DeepEqual(headers, map[string]string{"Cache-Control": RevalidateCacheControl}) {
+		t.Errorf("headers = %v", headers)
+	}
+}
+
+func TestIsFingerprinted(t *testing.T) {
+	tests := map[string]bool{
+		"/assets/index-BvL2qZ3c.js":        true,
+		"/static/css/main.3f9a1c2b.css":    true,
+		"/fonts/inter-a1b2c3d4.woff2":      true,
+		"/_next/static/chunks/app.js":      true,
+		"/_next/static/media/logo.svg":     true,
+		"/index.html":                      false,
+		"/app.js":                          false,
+		"/assets/my-component.js":          false,
+		"/assets/index-abcdefgh.js":        false,
+		"/assets/index-a1b2c3.js":          false,
+		"/assets/index-BvL2qZ3c.html":      false,
+		"/downloads/report-20240101.pdf":   false,
+		"/images/hero.8f14e45fce.webp":     true,
+		"/assets/vendor_Ab12Cd34Ef56.mjs":  false,
+		"/assets/vendor.Ab12Cd34Ef56.mjs":  true,
+		"/assets/vendor-Ab12Cd34Ef56.json": false,
+	}
+
+	for path, want := range tests {
+		if got := IsFingerprinted(path); got != want {
+			t.Errorf("IsFingerprinted(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package site
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// `<project>-<buildId>` serves one specific build, project names may contain dashes themselves
+// but can not end in `-<digits>`
+var pinnedLabelRegex = regexp.MustCompile(`^(.+)-([0-9]+)$`)
+
+// FromHost returns the site for a hostname. The first label names the project, or a single build
+// of it when it ends in `-<buildId>` and no project carries that full name. Only projects named
+// before such names were refused can still carry one, new names never shadow a pinned build.
+func FromHost(hostname string) (*Site, error) {
+	label := siteLabel(hostname)
+
+	site, err := Lookup(label)
+	if err != ErrNotFound {
+		return site, err
+	}
+
+	parts := pinnedLabelRegex.FindStringSubmatch(label)
+	if parts == nil {
+		return nil, ErrNotFound
+	}
+
+	buildId, convErr := strconv.Atoi(parts[2])
+	if convErr != nil {
+		return nil, ErrNotFound
+	}
+
+	return LookupBuild(parts[1], buildId)
+}
+
+// siteLabel strips BASE_DOMAIN from the hostname when it is set, otherwise the first label is used
+func siteLabel(hostname string) string {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	if baseDomain := strings.ToLower(os.Getenv("BASE_DOMAIN")); len(baseDomain) > 0 {
+		if label, found := strings.CutSuffix(hostname, "."+baseDomain); found && !strings.Contains(label, ".") {
+			return label
+		}
+	}
+
+	return strings.Split(hostname, ".")[0]
+}
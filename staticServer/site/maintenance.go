@@ -0,0 +1,53 @@
+package site
+
+import (
+	"net"
+	"time"
+)
+
+// InMaintenance tells whether the maintenance page should be served at the given time
+func (s *Site) InMaintenance(now time.Time) bool {
+	if !s.MaintenanceEnabled {
+		return false
+	}
+
+	if s.MaintenanceStartsAt.Valid && now.Before(s.MaintenanceStartsAt.Time) {
+		return false
+	}
+
+	if s.MaintenanceEndsAt.Valid && !now.Before(s.MaintenanceEndsAt.Time) {
+		return false
+	}
+
+	return true
+}
+
+// BypassesMaintenance checks the visitor's address against the project's allowlist
+func (s *Site) BypassesMaintenance(ip string) bool {
+	address := net.ParseIP(ip)
+	if address == nil {
+		return false
+	}
+
+	for _, network := range s.maintenanceAllowlist {
+		if network.Contains(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaintenanceRetryAfter is the number of seconds a client should wait, the end of the window when it is known
+func (s *Site) MaintenanceRetryAfter(now time.Time) int {
+	if !s.MaintenanceEndsAt.Valid {
+		return 300
+	}
+
+	seconds := int(s.MaintenanceEndsAt.Time.Sub(now).Seconds()) + 1
+	if seconds < 1 {
+		return 1
+	}
+
+	return seconds
+}
@@ -0,0 +1,133 @@
+package site
+
+import "strings"
+
+type Rule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+	Force  bool   `json:"force"`
+}
+
+type HeaderRule struct {
+	For    string            `json:"for"`
+	Values map[string]string `json:"values"`
+}
+
+type Function struct {
+	Route  string `json:"route"`
+	Object string `json:"object"`
+}
+
+type Config struct {
+	Redirects []Rule       `json:"redirects"`
+	Headers   []HeaderRule `json:"headers"`
+	Forms     []Form       `json:"forms"`
+	Functions []Function   `json:"functions"`
+}
+
+// FunctionFor returns the function answering the path, a trailing slash is ignored
+func (c Config) FunctionFor(path string) (*Function, bool) {
+	for index := range c.Functions {
+		if c.Functions[index].Route == strings.TrimSuffix(path, "/") {
+			return &c.Functions[index], true
+		}
+	}
+
+	return nil, false
+}
+
+// Match returns the first rule whose source matches the path, along with the destination
+// after `:splat` and the named placeholders were substituted.
+// When forced is true only rules marked with `!` are considered.
+func (c Config) Match(path string, forced bool) (*Rule, string, bool) {
+	for index := range c.Redirects {
+		rule := &c.Redirects[index]
+
+		if forced && !rule.Force {
+			continue
+		}
+
+		values, matched := match(rule.From, path)
+		if !matched {
+			continue
+		}
+
+		return rule, expand(rule.To, values), true
+	}
+
+	return nil, "", false
+}
+
+// IsExternal reports whether a rewrite should be proxied to another origin
+func (r Rule) IsExternal() bool {
+	return strings.HasPrefix(r.To, "http://") || strings.HasPrefix(r.To, "https://")
+}
+
+// KeepsOrigin reports whether the expanded destination of an external rule still points at the scheme and host
+// the rule was written with, configs built before placeholders were limited to the path could pick the host per request
+func (r Rule) KeepsOrigin(destination string) bool {
+	return externalOrigin(destination) == externalOrigin(r.To)
+}
+
+// externalOrigin is the scheme and host of an absolute url, everything before the path, query or fragment
+func externalOrigin(destination string) string {
+	scheme, rest, _ := strings.Cut(destination, "://")
+
+	if end := strings.IndexAny(rest, "/?#"); end != -1 {
+		rest = rest[:end]
+	}
+
+	return scheme + "://" + rest
+}
+
+func match(pattern, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	values := map[string]string{}
+
+	for index, segment := range patternSegments {
+		if segment == "*" {
+			values["splat"] = strings.Join(pathSegments[index:], "/")
+			return values, true
+		}
+
+		if index >= len(pathSegments) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(segment, ":") {
+			if len(pathSegments[index]) == 0 {
+				return nil, false
+			}
+			values[segment[1:]] = pathSegments[index]
+			continue
+		}
+
+		if segment != pathSegments[index] {
+			return nil, false
+		}
+	}
+
+	return values, len(patternSegments) == len(pathSegments)
+}
+
+func expand(destination string, values map[string]string) string {
+	// longest names first so `:id` never eats the start of `:identifier`
+	for {
+		longest := ""
+		for name := range values {
+			if len(name) > len(longest) && strings.Contains(destination, ":"+name) {
+				longest = name
+			}
+		}
+
+		if len(longest) == 0 {
+			return destination
+		}
+
+		destination = strings.ReplaceAll(destination, ":"+longest, values[longest])
+		delete(values, longest)
+	}
+}
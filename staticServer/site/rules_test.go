@@ -0,0 +1,116 @@
+package site
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	config := Config{Redirects: []Rule{
+		{From: "/old", To: "/new", Status: 301},
+		{From: "/blog/:year/:slug", To: "/posts/:slug?year=:year", Status: 302},
+		{From: "/docs/*", To: "/documentation/:splat", Status: 301},
+		{From: "/app/*", To: "/app/index.html", Status: 200},
+		{From: "/users/:id/:identifier", To: "/u/:identifier/:id", Status: 200},
+		{From: "/api/*", To: "https://api.example.com/:splat", Status: 200, Force: true},
+		{From: "/docs/intro", To: "/never", Status: 301},
+		{From: "/*", To: "/index.html", Status: 200},
+	}}
+
+	tests := []struct {
+		name        string
+		path        string
+		forced      bool
+		from        string
+		destination string
+		status      int
+	}{
+		{"literal", "/old", false, "/old", "/new", 301},
+		{"trailing slash", "/old/", false, "/old", "/new", 301},
+		{"placeholders", "/blog/2024/hello", false, "/blog/:year/:slug", "/posts/hello?year=2024", 302},
+		{"splat", "/docs/guide/install", false, "/docs/*", "/documentation/guide/install", 301},
+		{"empty splat", "/docs/", false, "/docs/*", "/documentation/", 301},
+		{"earlier rule wins", "/docs/intro", false, "/docs/*", "/documentation/intro", 301},
+		{"rewrite", "/app/settings/profile", false, "/app/*", "/app/index.html", 200},
+		{"placeholder names sharing a prefix", "/users/7/octo", false, "/users/:id/:identifier", "/u/octo/7", 200},
+		{"forced rule", "/api/v1/users", true, "/api/*", "https://api.example.com/v1/users", 200},
+		{"unforced rules are skipped in the forced pass", "/old", true, "", "", 0},
+		{"catch all", "/anything/else", false, "/*", "/index.html", 200},
+		{"missing placeholder segment", "/blog/2024", false, "/*", "/index.html", 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, destination, matched := config.Match(test.path, test.forced)
+
+			if len(test.from) == 0 {
+				if matched {
+					t.Fatalf("matched %s", rule.From)
+				}
+				return
+			}
+
+			if !matched {
+				t.Fatalf("no rule matched")
+			}
+			if rule.From != test.from {
+				t.Errorf("rule = %s, want %s", rule.From, test.from)
+			}
+			if destination != test.destination {
+				t.Errorf("destination = %s, want %s", destination, test.destination)
+			}
+			if rule.Status != test.status {
+				t.Errorf("status = %d, want %d", rule.Status, test.status)
+			}
+		})
+	}
+}
+
+func TestNoMatch(t *testing.T) {
+	config := Config{Redirects: []Rule{
+		{From: "/blog/:slug", To: "/posts/:slug", Status: 301},
+		{From: "/exact", To: "/elsewhere", Status: 302},
+	}}
+
+	for _, path := range []string{"/", "/blog", "/blog//", "/blog/a/b", "/exact/more", "/other"} {
+		t.Run(path, func(t *testing.T) {
+			if rule, _, matched := config.Match(path, false); matched {
+				t.Errorf("matched %s", rule.From)
+			}
+		})
+	}
+}
+
+func TestKeepsOrigin(t *testing.T) {
+	tests := []struct {
+		to          string
+		destination string
+		keeps       bool
+	}{
+		{"https://api.example.com/:splat", "https://api.example.com/v1/users", true},
+		{"https://api.example.com/:splat", "https://api.example.com?q=1", true},
+		{"https://:host/api", "https://evil.example/api", false},
+		{"https://api.example.com:splat", "https://api.example.com.evil.example/x", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.destination, func(t *testing.T) {
+			rule := Rule{To: test.to, Status: 200}
+
+			if !rule.IsExternal() {
+				t.Fatalf("%s is not external", test.to)
+			}
+			if keeps := rule.KeepsOrigin(test.destination); keeps != test.keeps {
+				t.Errorf("KeepsOrigin = %v, want %v", keeps, test.keeps)
+			}
+		})
+	}
+}
+
+func TestFunctionFor(t *testing.T) {
+	config := Config{Functions: []Function{{Route: "/api/hello", Object: "hello.wasm"}}}
+
+	if function, found := config.FunctionFor("/api/hello/"); !found || function.Object != "hello.wasm" {
+		t.Errorf("trailing slash did not find the function")
+	}
+	if _, found := config.FunctionFor("/api/hello/world"); found {
+		t.Errorf("a deeper path found the function")
+	}
+}
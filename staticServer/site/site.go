@@ -0,0 +1,206 @@
+package site
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"staticServer/config"
+	prom "staticServer/prometheus"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrNotFound = errors.New("[SITE] no active deployment")
+
+// how long a looked up site is reused before hitting the database again
+const cacheTTL = 10 * time.Second
+
+type Site struct {
+	ProjectId    int
+	Name         string
+	BuildId      int
+	SpaFallback  bool
+	Protection   string
+	PasswordHash string
+	Config       Config
+	// object name prefix the deployment's files are stored under, without the trailing slash
+	Prefix string
+	// set when a specific build was requested through its own hostname
+	Pinned bool
+	// requests per second and burst, zero means the static server default
+	RateLimit int
+	RateBurst int
+	// bytes per month, zero means unlimited
+	BandwidthQuota int64
+	QuotaPage      string
+	FormWebhookURL string
+	// html responses get the public runtime variables as window.__ENV
+	InjectRuntimeEnv bool
+	// the maintenance page is served between the optional bounds while enabled
+	MaintenanceEnabled  bool
+	MaintenanceStartsAt sql.NullTime
+	MaintenanceEndsAt   sql.NullTime
+	MaintenancePage     string
+	// visitors from these networks keep seeing the site during maintenance
+	maintenanceAllowlist []*net.IPNet
+	// candidate deployment receiving CanaryWeight percent of the visitors
+	Canary       *Site
+	CanaryWeight int
+	canaryBuild  int
+}
+
+// hostnames nobody deployed are cached as misses too, the bound keeps random subdomains from piling up
+const maxCachedSites = 10000
+
+type cacheEntry struct {
+	site *Site
+	err  error
+}
+
+var cache = newTTLCache[string, cacheEntry](maxCachedSites)
+
+// Lookup returns the active deployment of the project served on the given subdomain
+func Lookup(name string) (*Site, error) {
+	return cached(name, func() (*Site, error) {
+		return fetch(name)
+	})
+}
+
+// LookupBuild returns a deployment of the project for a build whose files are still kept, active or not
+func LookupBuild(name string, buildId int) (*Site, error) {
+	return cached(fmt.Sprintf("%s#%d", name, buildId), func() (*Site, error) {
+		return fetchBuild(name, buildId)
+	})
+}
+
+func cached(key string, load func() (*Site, error)) (*Site, error) {
+	entry, fresh := cache.Get(key)
+	countCache("site", fresh)
+	if fresh {
+		return entry.site, entry.err
+	}
+
+	site, err := load()
+
+	// database failures are not cached so the next request retries straight away
+	if err == nil || err == ErrNotFound {
+		cache.Set(key, cacheEntry{site: site, err: err}, cacheTTL)
+	}
+
+	return site, err
+}
+
+const siteColumns = `
+	p.id, p.spa_fallback, p.access_protection, COALESCE(p.access_password_hash, ''),
+	d.build_id, d.site_config, b.artifacts_kept, COALESCE(p.canary_build_id, 0), p.canary_weight,
+	COALESCE(p.rate_limit, 0), COALESCE(p.rate_burst, 0), COALESCE(p.bandwidth_quota, 0), COALESCE(p.quota_page, ''),
+	COALESCE(p.form_webhook_url, ''), p.inject_runtime_env,
+	p.maintenance_enabled, p.maintenance_starts_at, p.maintenance_ends_at, COALESCE(p.maintenance_page, ''), p.maintenance_allowlist
+`
+
+func fetch(name string) (*Site, error) {
+	query := `
+		SELECT ` + siteColumns + `
+		FROM "deploy-io".projects p
+		JOIN "deploy-io".deployments d ON d.project_id = p.id AND d.status = TRUE
+		JOIN "deploy-io".builds b ON b.id = d.build_id
+		WHERE p.name = $1 AND p.suspended = FALSE
+		ORDER BY d.created_at DESC LIMIT 1;
+	`
+
+	site, err := scan(config.DataBase.QueryRow(query, name), name)
+	if err != nil {
+		return nil, err
+	}
+
+	// a candidate older than the active deployment was overtaken by a regular deploy, it gets no traffic
+	if site.canaryBuild == 0 || site.canaryBuild <= site.BuildId || site.CanaryWeight == 0 {
+		return site, nil
+	}
+
+	canary, canaryErr := fetchBuild(name, site.canaryBuild)
+	if canaryErr == ErrNotFound {
+		// the candidate is still building or its files are gone, everyone stays on the active deployment
+		return site, nil
+	}
+	if canaryErr != nil {
+		return nil, canaryErr
+	}
+
+	canary.Pinned = false
+	site.Canary = canary
+
+	return site, nil
+}
+
+func fetchBuild(name string, buildId int) (*Site, error) {
+	query := `
+		SELECT ` + siteColumns + `
+		FROM "deploy-io".projects p
+		JOIN "deploy-io".deployments d ON d.project_id = p.id
+		JOIN "deploy-io".builds b ON b.id = d.build_id AND b.artifacts_kept = TRUE
+		WHERE p.name = $1 AND d.build_id = $2 AND p.suspended = FALSE
+		ORDER BY d.created_at DESC LIMIT 1;
+	`
+
+	site, err := scan(config.DataBase.QueryRow(query, name, buildId), name)
+	if err != nil {
+		return nil, err
+	}
+
+	site.Pinned = true
+
+	return site, nil
+}
+
+func scan(row *sql.Row, name string) (*Site, error) {
+	site := Site{Name: name}
+	var siteConfig []byte
+	var artifactsKept bool
+	var allowlist []string
+
+	err := row.Scan(&site.ProjectId, &site.SpaFallback, &site.Protection, &site.PasswordHash,
+		&site.BuildId, &siteConfig, &artifactsKept, &site.canaryBuild, &site.CanaryWeight,
+		&site.RateLimit, &site.RateBurst, &site.BandwidthQuota, &site.QuotaPage,
+		&site.FormWebhookURL, &site.InjectRuntimeEnv,
+		&site.MaintenanceEnabled, &site.MaintenanceStartsAt, &site.MaintenanceEndsAt, &site.MaintenancePage, pq.Array(&allowlist))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(siteConfig, &site.Config); err != nil {
+		return nil, err
+	}
+
+	// entries are validated when saved, anything unparsable is skipped rather than failing the whole site
+	for _, entry := range allowlist {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			site.maintenanceAllowlist = append(site.maintenanceAllowlist, network)
+		}
+	}
+
+	// deployments made before every build got its own prefix live under the project name
+	site.Prefix = name
+	if artifactsKept {
+		site.Prefix = fmt.Sprintf("_builds/%d", site.BuildId)
+	}
+
+	return &site, nil
+}
+
+func countCache(name string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	prom.CacheRequestCounter.With(prometheus.Labels{"cache": name, "result": result}).Inc()
+}
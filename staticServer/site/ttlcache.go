@@ -0,0 +1,91 @@
+package site
+
+import (
+	"sync"
+	"time"
+)
+
+// how often every cache drops the entries that expired without being read again
+const sweepInterval = time.Minute
+
+// ttlCache keeps entries until they expire and holds at most maxEntries of them. Its keys come from
+// requests, so without the bound every made up hostname or token would stay in memory for good.
+type ttlCache[K comparable, V any] struct {
+	mutex      sync.Mutex
+	entries    map[K]ttlEntry[V]
+	maxEntries int
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLCache[K comparable, V any](maxEntries int) *ttlCache[K, V] {
+	c := &ttlCache[K, V]{entries: map[K]ttlEntry[V]{}, maxEntries: maxEntries}
+
+	go func() {
+		for range time.Tick(sweepInterval) {
+			c.sweep()
+		}
+	}()
+
+	return c
+}
+
+// Get returns the value while it is fresh, an expired entry is removed on the way
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		var zero V
+		return zero, false
+	}
+
+	if !time.Now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+// Set stores the value for ttl. A full cache first drops what expired, then random entries
+func (c *ttlCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, found := c.entries[key]; !found && len(c.entries) >= c.maxEntries {
+		c.removeExpired()
+
+		// map iteration starts at a random entry, which is as good as any to give up
+		for evictKey := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, evictKey)
+		}
+	}
+
+	c.entries[key] = ttlEntry[V]{value: value, expiresAt: time.Now().Add(ttl)}
+}
+
+func (c *ttlCache[K, V]) sweep() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeExpired()
+}
+
+func (c *ttlCache[K, V]) removeExpired() {
+	now := time.Now()
+
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}